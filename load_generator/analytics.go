@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Analytics queries over the seeded data, logged as query type analytics_<query>. They aggregate whole tables, like
+// the queries of a reporting dashboard.
+var analyticsQueries = []struct {
+	name   string
+	query  string
+	fields []string
+}{
+	{"customers_by_country", `SELECT country, count() AS customers FROM customer GROUP BY country;`, []string{"country", "customers"}},
+	{"order_revenue", `SELECT id, math::sum(books.price) AS revenue FROM order ORDER BY revenue DESC LIMIT $limit;`, []string{"id", "revenue"}},
+	{"orders_per_month", `SELECT time::format(<datetime> created_at, '%Y-%m') AS month, count() AS orders FROM order GROUP BY month;`, []string{"month", "orders"}},
+	{"top_customers", `SELECT in AS customer, count() AS orders FROM ordered GROUP BY customer ORDER BY orders DESC LIMIT $limit;`, []string{"customer", "orders"}},
+}
+
+// Analytics modes. phase runs the analytics queries in their own phase per connection type after the CRUD phases,
+// concurrent runs them next to the CRUD phase of the same connection type and only skips the CRUD phases.
+const (
+	analyticsPhase      = "phase"
+	analyticsConcurrent = "concurrent"
+	analyticsOnly       = "only"
+)
+
+type analyticsConfig struct {
+	// mode is empty if the analytics queries are disabled
+	mode    string
+	workers int
+	// top is the number of orders and customers returned by order_revenue and top_customers
+	top int
+}
+
+func (cfg analyticsConfig) validate() error {
+	switch cfg.mode {
+	case "", analyticsPhase, analyticsConcurrent, analyticsOnly:
+	default:
+		return fmt.Errorf("unknown analytics mode %q", cfg.mode)
+	}
+	if cfg.workers < 1 {
+		return fmt.Errorf("invalid number of analytics workers %d", cfg.workers)
+	}
+	if cfg.top < 1 || cfg.top > queryLimit {
+		return fmt.Errorf("the number of top records has to be between 1 and %d", queryLimit)
+	}
+	return nil
+}
+
+// runAnalyticsBenchmark runs the analytics queries in a phase per connection type.
+func runAnalyticsBenchmark(duration time.Duration, cfg analyticsConfig) error {
+	log.Printf("Starting Analytics benchmark with %d workers for %d minutes per connection type \n", cfg.workers, int(duration.Minutes()))
+
+	for _, connection := range []string{"REST", "Websocket", "SDK"} {
+		runPhase("Analytics "+connection, duration, cfg.workers, runQueryWorkers(connection, false, cfg.run))
+	}
+
+	log.Println("Analytics benchmark finished")
+	return nil
+}
+
+// alongside starts the analytics workers of connection if the analytics queries run concurrently with the CRUD
+// phases. The returned function stops them and waits until they finished.
+func (cfg analyticsConfig) alongside(connection string) func() {
+	if cfg.mode != analyticsConcurrent {
+		return func() {}
+	}
+	log.Printf("Starting %d %s analytics workers next to the %s benchmark \n", cfg.workers, connection, connection)
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	wg := new(sync.WaitGroup)
+	worker := runQueryWorkers(connection, false, cfg.run)
+	for i := 0; i < cfg.workers; i++ {
+		go worker(wg, ctx)
+	}
+	return func() {
+		ctxCancel()
+		wg.Wait()
+		log.Printf("%s analytics workers stopped \n", connection)
+	}
+}
+
+// analyze runs a random analytics query and returns its name.
+func (cfg analyticsConfig) analyze(exec queryExecutor) (string, opStats, error) {
+	analytics := analyticsQueries[rand.Intn(len(analyticsQueries))]
+	resp, stats, err := exec(analytics.query, map[string]interface{}{"limit": cfg.top})
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), hasFields(analytics.fields...))
+	}
+	return analytics.name, stats, err
+}
+
+// run runs a random analytics query and logs it.
+func (cfg analyticsConfig) run(w queryWorker) (string, time.Time, error) {
+	start := time.Now()
+	name, stats, err := cfg.analyze(w.exec)
+	if err != nil {
+		return "analytics_" + name, start, err
+	}
+	saveResult(w.result("analytics_"+name, stats, start))
+	return "", start, nil
+}
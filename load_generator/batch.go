@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// batchCounter counts the requests and records inserted during one step of the batch size sweep. duration is the sum
+// of the insert durations, without the deletes after every insert.
+type batchCounter struct {
+	requests int64
+	records  int64
+	duration int64
+}
+
+func (c *batchCounter) add(records int, duration time.Duration) {
+	atomic.AddInt64(&c.requests, 1)
+	atomic.AddInt64(&c.records, int64(records))
+	atomic.AddInt64(&c.duration, int64(duration))
+}
+
+// parseSizes parses a comma separated list of positive sizes, e.g. "1,10,100,1000".
+func parseSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(list, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if size < 1 {
+			return nil, fmt.Errorf("invalid size %d", size)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// runBatchBenchmark sweeps the batch sizes for every connection type. The duration of each connection type is split
+// equally between the batch sizes.
+func runBatchBenchmark(duration time.Duration, workers int, sizes []int) error {
+	log.Printf("Starting Batch insert benchmark with %d workers for %d minutes per connection type and batch sizes %v \n", workers, int(duration.Minutes()), sizes)
+
+	stepDuration := duration / time.Duration(len(sizes))
+	for _, size := range sizes {
+		size := size
+		runBatchStep("REST", size, stepDuration, workers, func(wg *sync.WaitGroup, ctx context.Context, counter *batchCounter) error {
+			return restBatchWorker(wg, ctx, size, counter)
+		})
+	}
+	for _, size := range sizes {
+		size := size
+		runBatchStep("Websocket", size, stepDuration, workers, func(wg *sync.WaitGroup, ctx context.Context, counter *batchCounter) error {
+			return websocketBatchWorker(wg, ctx, size, counter)
+		})
+	}
+	for _, size := range sizes {
+		size := size
+		runBatchStep("SDK", size, stepDuration, workers, func(wg *sync.WaitGroup, ctx context.Context, counter *batchCounter) error {
+			return sdkBatchWorker(wg, ctx, size, counter)
+		})
+	}
+
+	log.Println("Batch insert benchmark finished")
+	return nil
+}
+
+func runBatchStep(connection string, size int, duration time.Duration, workers int, worker func(*sync.WaitGroup, context.Context, *batchCounter) error) {
+	counter := new(batchCounter)
+	runPhase(fmt.Sprintf("Batch %s %d", connection, size), duration, workers, func(wg *sync.WaitGroup, ctx context.Context) error {
+		return worker(wg, ctx, counter)
+	})
+	// The workers insert concurrently, so the time spent inserting is the summed insert duration per worker.
+	elapsed := time.Duration(counter.duration) / time.Duration(workers)
+
+	summary := BatchSummary{
+		ConnectionType:       connection,
+		BatchSize:            size,
+		Requests:             int(counter.requests),
+		Records:              int(counter.records),
+		DurationMicroSeconds: int(elapsed.Microseconds()),
+	}
+	if elapsed > 0 {
+		summary.RecordsPerSecond = float64(counter.records) / elapsed.Seconds()
+	}
+	log.Printf("Batch %s %d: %d records in %d requests, %.0f records/s \n", connection, size, summary.Records, summary.Requests, summary.RecordsPerSecond)
+	logBatchSummary(summary)
+}
+
+func customerBatch(size int) []map[string]interface{} {
+	batch := make([]map[string]interface{}, size)
+	for i := range batch {
+		batch[i] = testCustomer()
+	}
+	return batch
+}
+
+// recordIds returns the ids of the records of a statement result.
+func recordIds(records []interface{}) ([]string, error) {
+	ids := make([]string, len(records))
+	for i, record := range records {
+		fields, ok := record.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected record in response")
+		}
+		ids[i], ok = fields["id"].(string)
+		if !ok {
+			return nil, errors.New("record without id in response")
+		}
+	}
+	return ids, nil
+}
+
+// batchDeleteQuery deletes the inserted records again, so the customer table keeps its size during the benchmark.
+// It is sent without the phase context, so the records are also deleted if the phase ends in between.
+func batchDeleteQuery(ids []string) string {
+	return `DELETE ` + strings.Join(ids, ", ") + `;`
+}
+
+func restBatchWorker(wg *sync.WaitGroup, ctx context.Context, size int, counter *batchCounter) error {
+	trace := new(restTrace)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			wg.Add(1)
+
+			batch := customerBatch(size)
+			start := time.Now()
+			ids, stats, err := restBatchInsert(ctx, batch, trace)
+			if err != nil {
+				wg.Done()
+				if restRecover(ctx, "batch_insert", start, err) {
+					continue
+				}
+				return err
+			}
+			final := time.Since(start)
+			counter.add(len(ids), final)
+			res := newResult("REST", "batch_insert", stats, int(final.Microseconds())).withTrace(trace)
+			res.BatchSize = size
+			saveResult(res)
+
+			start = time.Now()
+			if _, _, err := doRequest(context.Background(), "POST", "/sql", strings.NewReader(batchDeleteQuery(ids)), decodeGeneric, trace); err != nil {
+				wg.Done()
+				if restRecover(ctx, "batch_delete", start, err) {
+					continue
+				}
+				return err
+			}
+
+			wg.Done()
+		}
+	}
+}
+
+func websocketBatchWorker(wg *sync.WaitGroup, ctx context.Context, size int, counter *batchCounter) error {
+	ws, err := prepareWebsocket()
+	if err != nil {
+		return err
+	}
+	nextId := 2
+	for {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+			return nil
+		default:
+			wg.Add(1)
+
+			batch := customerBatch(size)
+			start := time.Now()
+			ids, stats, err := websocketBatchInsert(ctx, batch, ws, nextId)
+			nextId++
+			if err != nil {
+				ws, err = websocketRecover(ctx, ws, "batch_insert", start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			final := time.Since(start)
+			counter.add(len(ids), final)
+			res := newResult("Websocket", "batch_insert", stats, int(final.Microseconds()))
+			res.BatchSize = size
+			saveResult(res)
+
+			start = time.Now()
+			_, _, err = wsSendMessage(context.Background(), ws, nextId, batchDeleteQuery(ids), nil)
+			nextId++
+			if err != nil {
+				ws, err = websocketRecover(ctx, ws, "batch_delete", start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			wg.Done()
+		}
+	}
+}
+
+func sdkBatchWorker(wg *sync.WaitGroup, ctx context.Context, size int, counter *batchCounter) error {
+	db, err := prepareSdk()
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil
+		default:
+			wg.Add(1)
+
+			batch := customerBatch(size)
+			start := time.Now()
+			ids, stats, err := sdkBatchInsert(ctx, batch, db)
+			if err != nil {
+				db, err = sdkRecover(ctx, db, "batch_insert", start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			final := time.Since(start)
+			counter.add(len(ids), final)
+			res := newResult("SDK", "batch_insert", stats, int(final.Microseconds()))
+			res.BatchSize = size
+			saveResult(res)
+
+			start = time.Now()
+			if _, err := sdkCall(context.Background(), func() (interface{}, error) { return db.Query(batchDeleteQuery(ids), nil) }); err != nil {
+				db, err = sdkRecover(ctx, db, "batch_delete", start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			wg.Done()
+		}
+	}
+}
+
+// restBatchInsert creates the records with a single POST /key/customer request.
+func restBatchInsert(ctx context.Context, batch []map[string]interface{}, trace *restTrace) ([]string, opStats, error) {
+	encodeStart := time.Now()
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return nil, opStats{}, err
+	}
+	encodeDuration := time.Since(encodeStart)
+	resp, stats, err := doRequest(ctx, "POST", "/key/customer", bytes.NewReader(body), decodeGeneric, trace)
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.EncodeDuration = int(encodeDuration.Microseconds())
+	records, _ := resp[0]["result"].([]interface{})
+	ids, err := recordIds(records)
+	if err != nil {
+		return nil, stats, err
+	}
+	return ids, stats, nil
+}
+
+// websocketBatchInsert creates the records with the insert RPC method, which doesn't report the internal duration.
+func websocketBatchInsert(ctx context.Context, batch []map[string]interface{}, ws wsConn, msgId int) ([]string, opStats, error) {
+	resp, stats, err := wsSendRpc(ctx, ws, msgId, "insert", "customer", batch)
+	if err != nil {
+		return nil, stats, err
+	}
+	records := make([]interface{}, len(resp))
+	for i, record := range resp {
+		records[i] = record
+	}
+	ids, err := recordIds(records)
+	if err != nil {
+		return nil, stats, err
+	}
+	return ids, stats, nil
+}
+
+// sdkBatchInsert creates the records with an INSERT INTO statement.
+func sdkBatchInsert(ctx context.Context, batch []map[string]interface{}, db *surrealdb.DB) ([]string, opStats, error) {
+	resp, stats, err := sdkQuery(ctx, db, `INSERT INTO customer $customers;`, map[string]interface{}{"customers": batch})
+	if err != nil {
+		return nil, stats, err
+	}
+	records, _ := resp[0]["result"].([]interface{})
+	ids, err := recordIds(records)
+	if err != nil {
+		return nil, stats, err
+	}
+	return ids, stats, nil
+}
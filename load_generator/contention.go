@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const contentionTable = "contention"
+
+// Query templates of the contention workload. The records have the numeric ids 0 to the number of records - 1 and a
+// counter starting at 0.
+const (
+	contentionIncrementQuery   = `UPDATE type::thing($tb, $id) SET counter += 1, updated_at = time::now() RETURN NONE;`
+	contentionReadQuery        = `SELECT VALUE counter FROM type::thing($tb, $id);`
+	contentionWriteQuery       = `UPDATE type::thing($tb, $id) SET counter = $counter, updated_at = time::now() RETURN NONE;`
+	contentionTransactionQuery = `BEGIN TRANSACTION;
+LET $counter = (SELECT VALUE counter FROM type::thing($tb, $id))[0];
+UPDATE type::thing($tb, $id) SET counter = $counter + 1, updated_at = time::now() RETURN NONE;
+COMMIT TRANSACTION;`
+	contentionTotalQuery = `SELECT VALUE counter FROM type::table($tb);`
+)
+
+// Contention modes, logged as query type contention_<mode>. increment lets the server add 1 to the counter, while
+// read_modify_write reads the counter and writes it incremented in a second request, so concurrent increments can
+// overwrite each other. transaction reads and writes the counter in a single transaction, which conflicts instead.
+const (
+	contentionIncrement       = "increment"
+	contentionReadModifyWrite = "read_modify_write"
+	contentionTransaction     = "transaction"
+)
+
+var contentionModes = []string{contentionIncrement, contentionReadModifyWrite, contentionTransaction}
+
+type contentionConfig struct {
+	// records is the number of shared records the workers increment, fewer records mean more contention
+	records int
+	retries int
+}
+
+// parseContentionModes parses a comma separated list of modes, e.g. "increment,transaction".
+func parseContentionModes(list string) ([]string, error) {
+	var modes []string
+	for _, field := range strings.Split(list, ",") {
+		mode := strings.ToLower(strings.TrimSpace(field))
+		known := false
+		for _, m := range contentionModes {
+			known = known || m == mode
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown contention mode %q", field)
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// contentionPhase is a mode running on a connection type. increments counts the acknowledged increments, which is
+// the expected sum of the counters at the end of the phase.
+type contentionPhase struct {
+	cfg        contentionConfig
+	mode       string
+	increments int64
+}
+
+func (p *contentionPhase) queryType() string {
+	return "contention_" + p.mode
+}
+
+// runContentionBenchmark runs every mode on every connection type on freshly loaded records and compares the sum of
+// the counters with the acknowledged increments afterwards.
+func runContentionBenchmark(duration time.Duration, workers int, modes []string, cfg contentionConfig) error {
+	log.Printf("Starting Contention benchmark with %d workers for %d minutes per mode and connection type on %d records \n", workers, int(duration.Minutes()), cfg.records)
+
+	for _, mode := range modes {
+		for _, connection := range []string{"REST", "Websocket", "SDK"} {
+			if err := contentionLoad(cfg); err != nil {
+				contentionCleanup()
+				return err
+			}
+			phase := &contentionPhase{cfg: cfg, mode: mode}
+			// the increments in flight at the end of the phase are completed and acknowledged before the counters are
+			// summed up
+			runPhase("Contention "+mode+" "+connection, duration, workers, runQueryWorkers(connection, true, phase.run))
+			if err := contentionSummary(connection, phase, workers); err != nil {
+				contentionCleanup()
+				return err
+			}
+		}
+	}
+
+	if err := contentionCleanup(); err != nil {
+		return err
+	}
+
+	log.Println("Contention benchmark finished")
+	return nil
+}
+
+// contentionLoad replaces the records of the contention table with cfg.records records with a counter of 0.
+func contentionLoad(cfg contentionConfig) error {
+	records := make([]map[string]interface{}, cfg.records)
+	for key := range records {
+		records[key] = map[string]interface{}{"id": key, "counter": 0}
+	}
+	if _, _, err := doQuery(context.Background(), `DELETE type::table($tb);`, map[string]interface{}{"tb": contentionTable}, new(restTrace)); err != nil {
+		return err
+	}
+	return insertRecords(contentionTable, records)
+}
+
+// contentionCleanup removes the contention table with its counters.
+func contentionCleanup() error {
+	_, _, err := doQuery(context.Background(), `REMOVE TABLE `+contentionTable+`;`, nil, new(restTrace))
+	return err
+}
+
+// contentionSummary logs the acknowledged increments, the sum of the counters and the conflicts and retries of a
+// phase. Failed increments may have been applied, so lost updates are only exact if the phase had no failures.
+func contentionSummary(connection string, phase *contentionPhase, workers int) error {
+	resp, _, err := doQuery(context.Background(), contentionTotalQuery, map[string]interface{}{"tb": contentionTable}, new(restTrace))
+	if err != nil {
+		return err
+	}
+	counters, _ := statementResult(resp).([]interface{})
+	var total int64
+	for _, counter := range counters {
+		value, ok := counter.(float64)
+		if !ok {
+			return fmt.Errorf("unexpected counter %v", counter)
+		}
+		total += int64(value)
+	}
+
+	summary := ContentionSummary{
+		ConnectionType: connection,
+		Mode:           phase.mode,
+		Records:        phase.cfg.records,
+		Workers:        workers,
+		Increments:     phase.increments,
+		FinalValue:     total,
+		LostUpdates:    phase.increments - total,
+	}
+	counts := []struct {
+		outcome string
+		count   *int64
+	}{
+		{outcomeConflict, &summary.Conflicts},
+		{outcomeRetry, &summary.Retries},
+		{outcomeFailed, &summary.Failures},
+		{outcomeTimeout, &summary.Timeouts},
+	}
+	for _, c := range counts {
+		if *c.count, err = countResults(connection, phase.queryType(), c.outcome); err != nil {
+			return err
+		}
+	}
+	logContentionSummary(summary)
+
+	log.Printf("Contention %s %s: %d increments, counters sum to %d, %d lost updates, %d conflicts, %d retries, %d failures, %d timeouts \n",
+		phase.mode, connection, summary.Increments, summary.FinalValue, summary.LostUpdates, summary.Conflicts, summary.Retries, summary.Failures, summary.Timeouts)
+	return nil
+}
+
+// increment increments a random record in the mode of the phase. A read-modify-write is a read and a write of the
+// same record, its stats are the sum of both.
+func (p *contentionPhase) increment(exec queryExecutor) (opStats, error) {
+	vars := map[string]interface{}{"tb": contentionTable, "id": rand.Intn(p.cfg.records)}
+	switch p.mode {
+	case contentionIncrement:
+		_, stats, err := exec(contentionIncrementQuery, vars)
+		return stats, err
+	case contentionTransaction:
+		_, stats, err := exec(contentionTransactionQuery, vars)
+		return stats, err
+	default:
+		resp, readStats, err := exec(contentionReadQuery, vars)
+		if err != nil {
+			return readStats, err
+		}
+		counters, _ := statementResult(resp).([]interface{})
+		if len(counters) != 1 {
+			return readStats, invalid("expected 1 counter, got %d", len(counters))
+		}
+		counter, ok := counters[0].(float64)
+		if !ok {
+			return readStats, invalid("unexpected counter %v", counters[0])
+		}
+		vars["counter"] = int64(counter) + 1
+		_, writeStats, err := exec(contentionWriteQuery, vars)
+		return readStats.add(writeStats), err
+	}
+}
+
+// run increments until it is acknowledged or conflicted more than retries times, and counts the acknowledged
+// increments. Only the transaction mode commits, increments of the other modes that succeed at the first attempt are
+// logged as ok.
+func (p *contentionPhase) run(w queryWorker) (string, time.Time, error) {
+	outcome := outcomeOk
+	if p.mode == contentionTransaction {
+		outcome = outcomeCommit
+	}
+	var start time.Time
+	acknowledged, err := runAttempts(p.cfg.retries, outcome, func() (Result, error) {
+		start = time.Now()
+		stats, err := p.increment(w.exec)
+		return w.result(p.queryType(), stats, start), err
+	})
+	if acknowledged {
+		atomic.AddInt64(&p.increments, 1)
+	}
+	return p.queryType(), start, err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// decoder decodes a list of statement results, which is the body of a REST response or the result of the query RPC
+// method. Downstream the statements are always handled as maps, typed decoders only wrap the typed records.
+type decoder func(data []byte) ([]map[string]interface{}, error)
+
+func decodeGeneric(data []byte) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	err := json.Unmarshal(data, &result)
+	return result, err
+}
+
+type typedStatement[T any] struct {
+	Status string `json:"status"`
+	Time   string `json:"time"`
+	Detail string `json:"detail"`
+	Result []T    `json:"result"`
+}
+
+// decodeTyped decodes the records of every statement into T. Failed statements have an error message instead of
+// records as result, so responses that can't be decoded are decoded generically if one of their statements failed.
+func decodeTyped[T any](data []byte) ([]map[string]interface{}, error) {
+	var statements []typedStatement[T]
+	if err := json.Unmarshal(data, &statements); err != nil {
+		result, genericErr := decodeGeneric(data)
+		if genericErr != nil || checkStatus(result) == nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	result := make([]map[string]interface{}, len(statements))
+	for i, statement := range statements {
+		records := make([]interface{}, len(statement.Result))
+		for j, record := range statement.Result {
+			records[j] = record
+		}
+		result[i] = map[string]interface{}{
+			"status": statement.Status,
+			"time":   statement.Time,
+			"detail": statement.Detail,
+			"result": records,
+		}
+	}
+	return result, nil
+}
+
+// Typed records of the benchmark operations.
+type (
+	typedCustomer struct {
+		ID        string `json:"id"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Country   string `json:"country"`
+		LastLogin string `json:"last_login"`
+	}
+	typedOrder struct {
+		ID        string   `json:"id"`
+		CreatedAt string   `json:"created_at"`
+		Processed bool     `json:"processed"`
+		Books     []string `json:"books"`
+	}
+	typedBookTitles struct {
+		Books struct {
+			Title []string `json:"title"`
+		} `json:"books"`
+	}
+	typedOrderCustomers struct {
+		Ordered struct {
+			Customer struct {
+				FirstName []string `json:"first_name"`
+			} `json:"<-customer"`
+		} `json:"<-ordered"`
+	}
+)
+
+// queryDecoder returns the decoder for the results of query, which decodes into the typed records of the query if
+// typedDecode is set and into maps otherwise.
+func queryDecoder(query string) decoder {
+	if !typedDecode {
+		return decodeGeneric
+	}
+	switch query {
+	case createQuery, readQuery, updateQuery, deleteQuery, seededUpdateQuery:
+		return decodeTyped[typedCustomer]
+	case selectQuery, simpleQuery:
+		return decodeTyped[typedOrder]
+	case joinRelationQuery, seededJoinRelationQuery:
+		return decodeTyped[typedBookTitles]
+	case joinGraphQuery, seededJoinGraphQuery:
+		return decodeTyped[typedOrderCustomers]
+	default:
+		return decodeGeneric
+	}
+}
+
+// customerDecoder returns the decoder for responses with customers, e.g. of the /key/customer endpoints.
+func customerDecoder() decoder {
+	return queryDecoder(readQuery)
+}
+
+// createdId returns the id of the record created by the first statement of resp.
+func createdId(resp []map[string]interface{}) string {
+	switch record := resp[0]["result"].([]interface{})[0].(type) {
+	case typedCustomer:
+		return record.ID
+	default:
+		return record.(map[string]interface{})["id"].(string)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const documentTable = "document"
+
+// Query templates of the document operations of the Websocket and SDK drivers. They return the records like the
+// /key endpoints used by REST.
+const (
+	documentCreateQuery = `CREATE type::thing($tb, $id) CONTENT $document;`
+	documentReadQuery   = `SELECT * FROM type::thing($tb, $id);`
+	documentUpdateQuery = `UPDATE type::thing($tb, $id) CONTENT $document;`
+	documentDeleteQuery = `DELETE type::thing($tb, $id);`
+)
+
+// documentBucket is a document size in bytes, picked with a probability proportional to weight. create and update
+// are the documents of that size sent by the create and update operations.
+type documentBucket struct {
+	size   int
+	weight int
+	create map[string]interface{}
+	update map[string]interface{}
+}
+
+type documentConfig struct {
+	buckets []documentBucket
+	// depth is the number of nested levels of a document, every level has an array of arrayLength strings
+	depth       int
+	arrayLength int
+}
+
+// parseDocumentSizes parses a comma separated list of document sizes in bytes with optional weights, e.g.
+// "1024:50,1048576:10". The weight defaults to 1.
+func parseDocumentSizes(list string) ([]documentBucket, error) {
+	var buckets []documentBucket
+	for _, field := range strings.Split(list, ",") {
+		sizeField, weightField, hasWeight := strings.Cut(strings.TrimSpace(field), ":")
+		size, err := strconv.Atoi(sizeField)
+		if err != nil {
+			return nil, err
+		}
+		weight := 1
+		if hasWeight {
+			if weight, err = strconv.Atoi(weightField); err != nil {
+				return nil, err
+			}
+		}
+		if size < 1 || weight < 1 {
+			return nil, fmt.Errorf("invalid document size %q", field)
+		}
+		buckets = append(buckets, documentBucket{size: size, weight: weight})
+	}
+	return buckets, nil
+}
+
+// documentPhase runs the document operations on a connection type. nextId numbers the documents of all phases.
+type documentPhase struct {
+	cfg         documentConfig
+	totalWeight int
+	nextId      int64
+}
+
+// runDocumentBenchmark generates the documents of every size and runs the document operations on every connection
+// type. The document table is removed afterwards.
+func runDocumentBenchmark(duration time.Duration, workers int, cfg documentConfig) error {
+	log.Printf("Starting Document benchmark with %d workers for %d minutes per connection type \n", workers, int(duration.Minutes()))
+
+	phase := &documentPhase{cfg: cfg}
+	for i := range cfg.buckets {
+		bucket := &cfg.buckets[i]
+		bucket.create = generateDocument(bucket.size, cfg.depth, cfg.arrayLength)
+		bucket.update = generateDocument(bucket.size, cfg.depth, cfg.arrayLength)
+		phase.totalWeight += bucket.weight
+	}
+
+	elapsed := make(map[string]time.Duration)
+	for _, connection := range []string{"REST", "Websocket", "SDK"} {
+		start := time.Now()
+		runPhase("Document "+connection, duration, workers, runQueryWorkers(connection, false, phase.run))
+		elapsed[connection] = time.Since(start)
+	}
+
+	if err := documentCleanup(); err != nil {
+		return err
+	}
+	if err := logDocumentReport(elapsed); err != nil {
+		return err
+	}
+
+	log.Println("Document benchmark finished")
+	return nil
+}
+
+// documentCleanup removes the document table with the documents left by failed operations.
+func documentCleanup() error {
+	_, err := unboundedQuery(`REMOVE TABLE ` + documentTable + `;`)
+	return err
+}
+
+// generateDocument returns a document of about size bytes when encoded as JSON. Every one of the depth levels has an
+// array of arrayLength random strings and the next level as nested, the string length fills the document up to size.
+func generateDocument(size int, depth int, arrayLength int) map[string]interface{} {
+	overhead, _ := json.Marshal(nestedDocument(depth, arrayLength, func() string { return "" }))
+	length := (size - len(overhead)) / (depth * arrayLength)
+	if length < 1 {
+		length = 1
+	}
+	return nestedDocument(depth, arrayLength, func() string { return randomString(length) })
+}
+
+func nestedDocument(depth int, arrayLength int, value func() string) map[string]interface{} {
+	values := make([]string, arrayLength)
+	for i := range values {
+		values[i] = value()
+	}
+	document := map[string]interface{}{"level": depth, "values": values}
+	if depth > 1 {
+		document["nested"] = nestedDocument(depth-1, arrayLength, value)
+	}
+	return document
+}
+
+// nextBucket picks a document size according to the weights.
+func (p *documentPhase) nextBucket() *documentBucket {
+	r := rand.Intn(p.totalWeight)
+	for i := range p.cfg.buckets {
+		if r < p.cfg.buckets[i].weight {
+			return &p.cfg.buckets[i]
+		}
+		r -= p.cfg.buckets[i].weight
+	}
+	return &p.cfg.buckets[len(p.cfg.buckets)-1]
+}
+
+// documentOps sends the document operations with the driver of a worker.
+type documentOps struct {
+	create func(id string, document map[string]interface{}) (opStats, error)
+	read   func(id string) (opStats, error)
+	update func(id string, document map[string]interface{}) (opStats, error)
+	delete func(id string) (opStats, error)
+}
+
+// run creates, reads, updates and deletes a document of a random size and logs every operation labelled with the
+// size. REST sends the documents to the /key endpoints, the other drivers send the document query templates.
+func (p *documentPhase) run(w queryWorker) (string, time.Time, error) {
+	ops := queryDocumentOps(w.exec)
+	if w.connection == "REST" {
+		ops = restDocumentOps(w.ctx, w.trace)
+	}
+	bucket := p.nextBucket()
+	id := "doc" + strconv.FormatInt(atomic.AddInt64(&p.nextId, 1), 10)
+	steps := []struct {
+		query string
+		run   func() (opStats, error)
+	}{
+		{"document_create", func() (opStats, error) { return ops.create(id, bucket.create) }},
+		{"document_read", func() (opStats, error) { return ops.read(id) }},
+		{"document_update", func() (opStats, error) { return ops.update(id, bucket.update) }},
+		{"document_delete", func() (opStats, error) { return ops.delete(id) }},
+	}
+	for _, step := range steps {
+		start := time.Now()
+		stats, err := step.run()
+		if err != nil {
+			return step.query, start, err
+		}
+		res := w.result(step.query, stats, start)
+		res.DocumentSize = bucket.size
+		saveResult(res)
+	}
+	return "", time.Time{}, nil
+}
+
+// validateDocument checks that the result of a create, read or update is the document id.
+func validateDocument(resp []map[string]interface{}, id string) error {
+	if !validateResponses {
+		return nil
+	}
+	return validateRecord(statementResult(resp), documentTable+":"+id)
+}
+
+func documentVars(id string, document map[string]interface{}) map[string]interface{} {
+	vars := map[string]interface{}{"tb": documentTable, "id": id}
+	if document != nil {
+		vars["document"] = document
+	}
+	return vars
+}
+
+// restDocumentOps sends the documents to the /key endpoints. Encoding the document is measured as encode duration.
+func restDocumentOps(ctx context.Context, trace *restTrace) documentOps {
+	send := func(method string, id string, document map[string]interface{}) ([]map[string]interface{}, opStats, error) {
+		var body io.Reader
+		encodeDuration := time.Duration(0)
+		if document != nil {
+			encodeStart := time.Now()
+			encoded, err := json.Marshal(document)
+			if err != nil {
+				return nil, opStats{}, err
+			}
+			encodeDuration = time.Since(encodeStart)
+			body = bytes.NewReader(encoded)
+		}
+		resp, stats, err := doRequest(ctx, method, "/key/"+documentTable+"/"+id, body, decodeGeneric, trace)
+		stats.EncodeDuration = int(encodeDuration.Microseconds())
+		return resp, stats, err
+	}
+	write := func(method string) func(string, map[string]interface{}) (opStats, error) {
+		return func(id string, document map[string]interface{}) (opStats, error) {
+			resp, stats, err := send(method, id, document)
+			if err == nil {
+				err = validateDocument(resp, id)
+			}
+			return stats, err
+		}
+	}
+	return documentOps{
+		create: write("POST"),
+		update: write("PUT"),
+		read: func(id string) (opStats, error) {
+			resp, stats, err := send("GET", id, nil)
+			if err == nil {
+				err = validateDocument(resp, id)
+			}
+			return stats, err
+		},
+		delete: func(id string) (opStats, error) {
+			_, stats, err := send("DELETE", id, nil)
+			return stats, err
+		},
+	}
+}
+
+// queryDocumentOps sends the document query templates with exec.
+func queryDocumentOps(exec queryExecutor) documentOps {
+	write := func(query string) func(string, map[string]interface{}) (opStats, error) {
+		return func(id string, document map[string]interface{}) (opStats, error) {
+			resp, stats, err := exec(query, documentVars(id, document))
+			if err == nil {
+				err = validateDocument(resp, id)
+			}
+			return stats, err
+		}
+	}
+	return documentOps{
+		create: write(documentCreateQuery),
+		update: write(documentUpdateQuery),
+		read: func(id string) (opStats, error) {
+			resp, stats, err := exec(documentReadQuery, documentVars(id, nil))
+			if err == nil {
+				err = validateDocument(resp, id)
+			}
+			return stats, err
+		},
+		delete: func(id string) (opStats, error) {
+			_, stats, err := exec(documentDeleteQuery, documentVars(id, nil))
+			return stats, err
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Index states of the index experiment, stored in the index_state column of the results.
+const (
+	withoutIndexes = "without_indexes"
+	withIndexes    = "with_indexes"
+)
+
+// indexState labels the results of the CRUD phases of the index experiment. It's empty outside the experiment and
+// only changed between phases.
+var indexState string
+
+// writeQueries are the query types of the CRUD phases that write records, so indexes slow them down.
+var writeQueries = map[string]bool{"create": true, "update": true, "delete": true}
+
+// experimentIndex is an index of the experiment on one or more fields of a table.
+type experimentIndex struct {
+	table  string
+	fields []string
+}
+
+func (i experimentIndex) name() string {
+	return "benchmark_" + i.table + "_" + strings.Join(i.fields, "_")
+}
+
+func (i experimentIndex) definition() string {
+	return `DEFINE INDEX ` + i.name() + ` ON ` + i.table + ` FIELDS ` + strings.Join(i.fields, ", ") + `;`
+}
+
+// parseExperimentIndexes parses a comma separated list of indexes as table.field, with + between the fields of a
+// composite index, e.g. "order.processed,customer.email,order.processed+created_at".
+func parseExperimentIndexes(list string) ([]experimentIndex, error) {
+	var indexes []experimentIndex
+	for _, field := range strings.Split(list, ",") {
+		table, fields, ok := strings.Cut(strings.TrimSpace(field), ".")
+		if !ok || table == "" || fields == "" {
+			return nil, fmt.Errorf("invalid index %q, expected table.field", field)
+		}
+		indexes = append(indexes, experimentIndex{table: table, fields: strings.Split(fields, "+")})
+	}
+	return indexes, nil
+}
+
+// runIndexExperiment runs the CRUD phases without indexes, defines the indexes, waits until they are built, runs the
+// CRUD phases again and drops the indexes. The average durations of both runs are compared per query type.
+func runIndexExperiment(duration time.Duration, workers int, sdkMethods bool, indexes []experimentIndex) error {
+	log.Printf("Starting Index experiment with %d indexes \n", len(indexes))
+
+	indexState = withoutIndexes
+	if err := runExperimentRound(duration, workers, sdkMethods); err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if err := buildIndex("experiment", index.table, index.name(), index.definition()); err != nil {
+			dropExperimentIndexes(indexes)
+			return err
+		}
+		if err := waitForIndex(index.table, index.name()); err != nil {
+			dropExperimentIndexes(indexes)
+			return err
+		}
+	}
+
+	indexState = withIndexes
+	err := runExperimentRound(duration, workers, sdkMethods)
+	indexState = ""
+	if err != nil {
+		dropExperimentIndexes(indexes)
+		return err
+	}
+
+	if err := dropExperimentIndexes(indexes); err != nil {
+		return err
+	}
+	if err := logIndexExperiment(); err != nil {
+		return err
+	}
+
+	log.Println("Index experiment finished")
+	return nil
+}
+
+// runExperimentRound runs the CRUD phases of every connection type once.
+func runExperimentRound(duration time.Duration, workers int, sdkMethods bool) error {
+	log.Printf("Running the CRUD phases %s \n", strings.ReplaceAll(indexState, "_", " "))
+	if err := runRestBenchmark(duration, workers); err != nil {
+		return err
+	}
+	if err := runWebsocketBenchmark(duration, workers); err != nil {
+		return err
+	}
+	if err := runSdkBenchmark(duration, workers, sdkMethods); err != nil {
+		return err
+	}
+	if seededKeys != nil {
+		return seededCleanup()
+	}
+	return nil
+}
+
+// waitForIndex polls the build status of an index until it's ready. Servers that build indexes before DEFINE INDEX
+// responds don't report a build status, their indexes are ready right away.
+func waitForIndex(table string, name string) error {
+	for {
+		resp, err := unboundedQuery(`INFO FOR INDEX ` + name + ` ON ` + table + `;`)
+		if isQueryError(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info, _ := statementResult(resp).(map[string]interface{})
+		building, _ := info["building"].(map[string]interface{})
+		status, _ := building["status"].(string)
+		switch status {
+		case "", "ready":
+			return nil
+		case "error":
+			return fmt.Errorf("building index %s failed: %v", name, building["error"])
+		}
+		log.Printf("Waiting for index %s: %s \n", name, status)
+		time.Sleep(time.Second)
+	}
+}
+
+// dropExperimentIndexes removes the indexes of the experiment. Indexes that weren't defined because the experiment
+// failed are skipped.
+func dropExperimentIndexes(indexes []experimentIndex) error {
+	for _, index := range indexes {
+		if err := removeIndex(index.table, index.name()); err != nil && !isQueryError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// logIndexExperiment compares the average durations of the successful operations with and without indexes and
+// stores the comparison per connection and query type.
+func logIndexExperiment() error {
+	rows, err := compareIndexStates()
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.AvgWithoutIndexesMicroSeconds == 0 || row.AvgWithIndexesMicroSeconds == 0 {
+			log.Printf("%s %s: no successful operations to compare \n", row.ConnectionType, row.QueryType)
+			continue
+		}
+		row.Speedup = row.AvgWithoutIndexesMicroSeconds / row.AvgWithIndexesMicroSeconds
+		row.Write = writeQueries[row.QueryType]
+		logIndexExperimentSummary(row)
+		change := fmt.Sprintf("%.2fx faster", row.Speedup)
+		if row.Speedup < 1 {
+			change = fmt.Sprintf("%.2fx slower", 1/row.Speedup)
+		}
+		log.Printf("%s %s: avg %.0fµs without indexes, %.0fµs with indexes, %s \n", row.ConnectionType, row.QueryType, row.AvgWithoutIndexesMicroSeconds, row.AvgWithIndexesMicroSeconds, change)
+	}
+	return nil
+}
@@ -3,15 +3,15 @@ module load_generator
 go 1.18
 
 require (
+	github.com/gorilla/websocket v1.5.0
+	github.com/surrealdb/surrealdb.go v0.2.1
+	golang.org/x/net v0.20.0
 	gorm.io/driver/sqlite v1.5.4
 	gorm.io/gorm v1.25.6
 )
 
 require (
-	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/mattn/go-sqlite3 v1.14.22 // indirect
-	github.com/surrealdb/surrealdb.go v0.2.1 // indirect
-	golang.org/x/net v0.20.0 // indirect
 )
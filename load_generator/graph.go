@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Graph traversals from a customer, logged as query type graph_<traversal>. follows reaches the customers over depth
+// follows hops, e.g. the friends of friends at depth 2. follows_ordered and follows_reviewed reach the books ordered or
+// reviewed by these customers.
+const (
+	graphFollows         = "follows"
+	graphFollowsOrdered  = "follows_ordered"
+	graphFollowsReviewed = "follows_reviewed"
+)
+
+var graphTraversals = []string{graphFollows, graphFollowsOrdered, graphFollowsReviewed}
+
+// graphQuery returns the query of traversal at depth. It returns the out-degree of the customer, which is the fan-out
+// of the first hop, and the distinct records reached by the traversal.
+func graphQuery(traversal string, depth int) string {
+	path := strings.Repeat("->follows->customer", depth)
+	switch traversal {
+	case graphFollowsOrdered:
+		path += "->ordered->order.books"
+	case graphFollowsReviewed:
+		path += "->reviewed->book"
+	}
+	return `SELECT array::len(->follows->customer) AS degree, array::distinct(array::flatten(` + path + `)) AS reached FROM type::thing($tb, $id);`
+}
+
+// graphPhase runs the traversals at a depth on a connection type.
+type graphPhase struct {
+	depth   int
+	queries map[string]string
+}
+
+// runGraphBenchmark runs the traversals at every depth on every connection type. The dataset has to be generated with
+// follows and reviewed edges.
+func runGraphBenchmark(duration time.Duration, workers int, depths []int) error {
+	log.Printf("Starting Graph benchmark with %d workers for %d minutes per depth and connection type \n", workers, int(duration.Minutes()))
+
+	if err := checkGraphEdges(); err != nil {
+		return err
+	}
+
+	for _, depth := range depths {
+		phase := &graphPhase{depth: depth, queries: make(map[string]string)}
+		for _, traversal := range graphTraversals {
+			phase.queries[traversal] = graphQuery(traversal, depth)
+		}
+		name := fmt.Sprintf("Graph depth %d", depth)
+
+		for _, connection := range []string{"REST", "Websocket", "SDK"} {
+			runPhase(name+" "+connection, duration, workers, runQueryWorkers(connection, false, phase.run))
+		}
+	}
+
+	if err := logGraphReport(); err != nil {
+		return err
+	}
+
+	log.Println("Graph benchmark finished")
+	return nil
+}
+
+// checkGraphEdges fails if the dataset has no follows or reviewed edges.
+func checkGraphEdges() error {
+	resp, _, err := doQuery(context.Background(), `SELECT VALUE id FROM follows LIMIT 1; SELECT VALUE id FROM reviewed LIMIT 1;`, nil, new(restTrace))
+	if err != nil {
+		return err
+	}
+	for i, edge := range []string{"follows", "reviewed"} {
+		if edges, _ := resp[i]["result"].([]interface{}); len(edges) == 0 {
+			return fmt.Errorf("the dataset has no %s edges, generate it with prepare_db --follows-degree and --reviews-degree", edge)
+		}
+	}
+	return nil
+}
+
+// traverse runs a random traversal from a random customer. The records of the stats are the reached records, the
+// fan-out is the out-degree of the customer.
+func (p *graphPhase) traverse(exec queryExecutor) (string, opStats, int, error) {
+	traversal := graphTraversals[rand.Intn(len(graphTraversals))]
+	vars := map[string]interface{}{"tb": "customer", "id": rand.Int63n(customerCount)}
+	resp, stats, err := exec(p.queries[traversal], vars)
+	if err != nil {
+		return traversal, stats, 0, err
+	}
+	rows, err := recordList(statementResult(resp))
+	if err == nil && len(rows) != 1 {
+		err = invalid("expected 1 customer, got %d", len(rows))
+	}
+	if err != nil {
+		return traversal, stats, 0, err
+	}
+	row, _ := rows[0].(map[string]interface{})
+	degree, _ := row["degree"].(float64)
+	reached, _ := row["reached"].([]interface{})
+	stats.Records = len(reached)
+	return traversal, stats, int(degree), nil
+}
+
+// run runs a traversal and logs it labelled with the depth and fan-out.
+func (p *graphPhase) run(w queryWorker) (string, time.Time, error) {
+	start := time.Now()
+	traversal, stats, fanOut, err := p.traverse(w.exec)
+	if err != nil {
+		return "graph_" + traversal, start, err
+	}
+	res := w.result("graph_"+traversal, stats, start)
+	res.Depth = p.depth
+	res.FanOut = fanOut
+	saveResult(res)
+	return "", start, nil
+}
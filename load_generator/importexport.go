@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// importTable is the table the generated datasets are imported into. It's removed after every import.
+const importTable = "import_customer"
+
+// runImportExportBenchmark imports a generated dataset of every size and exports the benchmark database once.
+// Both requests are only bounded by the end of the transfer, not by the operation timeout.
+func runImportExportBenchmark(sizes []int) error {
+	log.Printf("Starting Import/Export benchmark with dataset sizes %v \n", sizes)
+
+	for _, size := range sizes {
+		if err := runImport(size); err != nil {
+			return err
+		}
+	}
+	if err := runExport(); err != nil {
+		return err
+	}
+
+	log.Println("Import/Export benchmark finished")
+	return nil
+}
+
+// runImport imports a dataset of records customers and removes it again, also if the import failed.
+func runImport(records int) error {
+	dataset := importDataset(records)
+	size := dataset.Len()
+	start := time.Now()
+	_, err := transferRequest("POST", "/import", dataset)
+	elapsed := time.Since(start)
+	if err == nil {
+		err = checkImported(records)
+	}
+	if cleanupErr := importCleanup(); err == nil {
+		err = cleanupErr
+	}
+	if err != nil {
+		return err
+	}
+	logTransfer("import", records, size, elapsed)
+	return nil
+}
+
+// runExport exports the database, whose records are counted beforehand. Besides the seeded dataset it contains the
+// edges generated by prepare_db and any records left by earlier phases.
+func runExport() error {
+	records, err := countDatabaseRecords()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	size, err := transferRequest("GET", "/export", nil)
+	if err != nil {
+		return err
+	}
+	logTransfer("export", records, int(size), time.Since(start))
+	return nil
+}
+
+// countDatabaseRecords sums up the records of all tables of the database. 1.x releases list the tables of INFO FOR DB
+// as tb, 2.x releases as tables.
+func countDatabaseRecords() (int, error) {
+	resp, err := unboundedQuery(`INFO FOR DB;`)
+	if err != nil {
+		return 0, err
+	}
+	info, _ := statementResult(resp).(map[string]interface{})
+	tables, ok := info["tables"].(map[string]interface{})
+	if !ok {
+		tables, _ = info["tb"].(map[string]interface{})
+	}
+	total := 0
+	for table := range tables {
+		count, err := countRecordsOf(table)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// countRecordsOf returns the number of records of table.
+func countRecordsOf(table string) (int, error) {
+	resp, err := unboundedQuery(`SELECT count() FROM ` + table + ` GROUP ALL;`)
+	if err != nil {
+		return 0, err
+	}
+	var count float64
+	if rows, _ := statementResult(resp).([]interface{}); len(rows) > 0 {
+		row, _ := rows[0].(map[string]interface{})
+		count, _ = row["count"].(float64)
+	}
+	return int(count), nil
+}
+
+// importDataset generates records customers in the format of prepare_db.
+func importDataset(records int) *bytes.Buffer {
+	dataset := new(bytes.Buffer)
+	dataset.WriteString("OPTION IMPORT;\n")
+	dataset.WriteString("DEFINE TABLE " + importTable + " SCHEMALESS PERMISSIONS NONE;\n")
+	dataset.WriteString("BEGIN TRANSACTION;\n")
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(dataset, "CREATE %s:%d SET first_name = 'Test', last_name = 'Tester', email = 'test%d@test.com', country = 'Germany', last_login = \"2024-02-03T21:31:22.000Z\" RETURN NONE;\n", importTable, i, i)
+	}
+	dataset.WriteString("COMMIT TRANSACTION;\n")
+	return dataset
+}
+
+// transferRequest sends a request with the namespace and database headers and returns the size of the response body.
+func transferRequest(method string, path string, body io.Reader) (int64, error) {
+	req, err := http.NewRequest(method, url+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	serverDialect.setHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	size, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return size, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return size, fmt.Errorf("%s %s failed: %v", method, path, resp.Status)
+	}
+	return size, nil
+}
+
+// checkImported checks that the import created all records, as /import reports failed statements inconsistently.
+func checkImported(records int) error {
+	count, err := countRecordsOf(importTable)
+	if err != nil {
+		return err
+	}
+	if count != records {
+		return fmt.Errorf("imported %d of %d records", count, records)
+	}
+	return nil
+}
+
+// importCleanup removes the import table with the records of the last import.
+func importCleanup() error {
+	_, _, err := doQuery(context.Background(), `REMOVE TABLE `+importTable+`;`, nil, new(restTrace))
+	return err
+}
+
+func logTransfer(operation string, records int, size int, elapsed time.Duration) {
+	summary := TransferSummary{
+		Operation:            operation,
+		Records:              records,
+		Bytes:                size,
+		DurationMicroSeconds: int(elapsed.Microseconds()),
+		BytesPerSecond:       float64(size) / elapsed.Seconds(),
+		RecordsPerSecond:     float64(records) / elapsed.Seconds(),
+	}
+	log.Printf("%s of %d records (%d bytes) took %v: %.0f bytes/s, %.0f records/s \n", operation, records, size, elapsed, summary.BytesPerSecond, summary.RecordsPerSecond)
+	logTransferSummary(summary)
+}
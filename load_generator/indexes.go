@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// unboundedQuery sends query to /sql without the operation timeout, for statements that take as long as the server
+// needs, like index builds.
+func unboundedQuery(query string) ([]map[string]interface{}, error) {
+	req, err := http.NewRequest("POST", url+"/sql", strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	serverDialect.setHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed: %v", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeGeneric(body)
+	if err != nil {
+		return nil, err
+	}
+	return result, checkStatus(result)
+}
+
+// buildIndex runs the DEFINE INDEX statement definition of the index name on table and stores its duration. SurrealDB
+// builds the index before it responds, so the duration of the request is the build time.
+func buildIndex(phase string, table string, name string, definition string) error {
+	start := time.Now()
+	_, err := unboundedQuery(definition)
+	build := IndexBuild{
+		Phase:                phase,
+		Table:                table,
+		Name:                 name,
+		Definition:           definition,
+		DurationMicroSeconds: int(time.Since(start).Microseconds()),
+	}
+	if err != nil {
+		build.Error = err.Error()
+		logIndexBuild(build)
+		return fmt.Errorf("building index %s failed: %w", name, err)
+	}
+	logIndexBuild(build)
+	log.Printf("Built index %s on %s in %v \n", name, table, time.Duration(build.DurationMicroSeconds)*time.Microsecond)
+	return nil
+}
+
+// removeIndex removes the index name on table.
+func removeIndex(table string, name string) error {
+	_, err := unboundedQuery(`REMOVE INDEX ` + name + ` ON ` + table + `;`)
+	return err
+}
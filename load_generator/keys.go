@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// Key distributions, named as in YCSB.
+const (
+	distributionUniform = "uniform"
+	distributionZipfian = "zipfian"
+	distributionLatest  = "latest"
+	distributionHotspot = "hotspot"
+)
+
+// hotsetFraction is the fraction of the keys that form the hot set of the hotspot distribution.
+const hotsetFraction = 0.2
+
+// keyChooser picks keys from 0 to the number of records - 1.
+type keyChooser interface {
+	next() int64
+}
+
+// newKeyChooser returns a chooser of the distribution over records keys. skew is the zipfian constant of the
+// zipfian and latest distributions, between 0 and 1 exclusive, and the fraction of the operations on the hot set of
+// the hotspot distribution. latest returns the current number of acknowledged records, which grows with inserts; the
+// uniform and hotspot distributions only pick from the initial records.
+func newKeyChooser(distribution string, skew float64, records int64, latest func() int64) (keyChooser, error) {
+	if records < 1 {
+		return nil, fmt.Errorf("invalid number of records %d", records)
+	}
+	switch distribution {
+	case distributionUniform:
+		return uniformKeys{records: records}, nil
+	case distributionZipfian, distributionLatest:
+		if skew <= 0 || skew >= 1 {
+			return nil, fmt.Errorf("invalid zipfian constant %v", skew)
+		}
+		zipf := newZipfian(records, skew)
+		if distribution == distributionLatest {
+			return latestKeys{zipf: zipf, latest: latest}, nil
+		}
+		return scrambledZipfianKeys{zipf: zipf, latest: latest}, nil
+	case distributionHotspot:
+		if skew < 0 || skew > 1 {
+			return nil, fmt.Errorf("invalid hot operation fraction %v", skew)
+		}
+		hotset := int64(float64(records) * hotsetFraction)
+		if hotset < 1 {
+			hotset = 1
+		}
+		return hotspotKeys{records: records, hotset: hotset, hotOperations: skew}, nil
+	default:
+		return nil, fmt.Errorf("unknown key distribution %q", distribution)
+	}
+}
+
+type uniformKeys struct {
+	records int64
+}
+
+func (k uniformKeys) next() int64 {
+	return rand.Int63n(k.records)
+}
+
+// zipfian draws ranks from 0 to items - 1, rank 0 being the most popular, with the algorithm of Gray et al., "Quickly
+// Generating Billion-Record Synthetic Databases", as used by YCSB.
+type zipfian struct {
+	items int64
+	theta float64
+	zetan float64
+	alpha float64
+	eta   float64
+}
+
+func newZipfian(items int64, theta float64) *zipfian {
+	zeta2 := zeta(2, theta)
+	zetan := zeta(items, theta)
+	return &zipfian{
+		items: items,
+		theta: theta,
+		zetan: zetan,
+		alpha: 1 / (1 - theta),
+		eta:   (1 - math.Pow(2/float64(items), 1-theta)) / (1 - zeta2/zetan),
+	}
+}
+
+func zeta(n int64, theta float64) float64 {
+	sum := 0.0
+	for i := int64(1); i <= n; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+	return sum
+}
+
+func (z *zipfian) next() int64 {
+	u := rand.Float64()
+	uz := u * z.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return 1
+	}
+	rank := int64(float64(z.items) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if rank >= z.items {
+		rank = z.items - 1
+	}
+	return rank
+}
+
+// scrambledZipfianKeys spreads the popular ranks of a zipfian distribution over the key space by hashing them, so
+// the popular keys aren't clustered at the start of the table. The key space includes the acknowledged inserts.
+type scrambledZipfianKeys struct {
+	zipf   *zipfian
+	latest func() int64
+}
+
+func (k scrambledZipfianKeys) next() int64 {
+	h := fnv.New64a()
+	rank := k.zipf.next()
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(rank >> (8 * i))
+	}
+	h.Write(b[:])
+	return int64(h.Sum64() % uint64(k.latest()))
+}
+
+// latestKeys prefers the most recently inserted keys.
+type latestKeys struct {
+	zipf   *zipfian
+	latest func() int64
+}
+
+func (k latestKeys) next() int64 {
+	key := k.latest() - 1 - k.zipf.next()
+	if key < 0 {
+		return 0
+	}
+	return key
+}
+
+// hotspotKeys sends hotOperations of the operations uniformly to the first hotset keys and the rest uniformly to the
+// other keys.
+type hotspotKeys struct {
+	records       int64
+	hotset        int64
+	hotOperations float64
+}
+
+func (k hotspotKeys) next() int64 {
+	if rand.Float64() < k.hotOperations || k.hotset == k.records {
+		return rand.Int63n(k.hotset)
+	}
+	return k.hotset + rand.Int63n(k.records-k.hotset)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	liveTable = "live_event"
+	// liveDrainTimeout is how long subscribers get to receive outstanding notifications after the writers stopped.
+	liveDrainTimeout = 10 * time.Second
+)
+
+type websocketRawReceive struct {
+	Id     interface{}     `json:"id"`
+	Result json.RawMessage `json:"result"`
+}
+
+type liveMessage struct {
+	Id     string `json:"id"`
+	Action string `json:"action"`
+	Result struct {
+		Id     string `json:"id"`
+		Seq    int    `json:"seq"`
+		SentAt int64  `json:"sent_at"`
+	} `json:"result"`
+}
+
+type liveSubscriber struct {
+	received      int64
+	index         int
+	ws            wsConn
+	liveId        string
+	notifications []LiveNotification
+	seen          map[string]struct{}
+}
+
+func runLiveBenchmark(duration time.Duration, workers int, subscribers int) error {
+	log.Printf("Starting Live query benchmark with %d writers and %d subscribers for %d minutes \n", workers, subscribers, int(duration.Minutes()))
+
+	subs := make([]*liveSubscriber, subscribers)
+	for i := range subs {
+		sub, err := newLiveSubscriber(i)
+		if err != nil {
+			return err
+		}
+		subs[i] = sub
+	}
+	subWg := new(sync.WaitGroup)
+	for _, sub := range subs {
+		subWg.Add(1)
+		go sub.listen(subWg)
+	}
+
+	var writes int64
+	ctx, ctxCancel := context.WithTimeout(context.Background(), duration)
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+		go liveWriter(wg, ctx, i, &writes)
+	}
+
+	for range ctx.Done() {
+		log.Println("Live query benchmark timeout. Stopping workers.")
+		break
+	}
+	wg.Wait()
+	ctxCancel()
+
+	expected := atomic.LoadInt64(&writes)
+	deadline := time.Now().Add(liveDrainTimeout)
+	for time.Now().Before(deadline) && !liveSubscribersDone(subs, expected) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	for _, sub := range subs {
+		if err := sub.kill(); err != nil {
+			log.Println(err)
+			sub.ws.Close()
+		}
+	}
+	subWg.Wait()
+
+	for _, sub := range subs {
+		// only acknowledged writes are expected, but writes in flight at the end of the phase may notify as well
+		received := len(sub.seen)
+		summary := LiveSubscriberSummary{
+			Subscriber:            sub.index,
+			ExpectedNotifications: int(expected),
+			ReceivedNotifications: received,
+		}
+		if received < int(expected) {
+			summary.MissedNotifications = int(expected) - received
+			log.Printf("Live subscriber %d missed %d of %d notifications \n", sub.index, summary.MissedNotifications, expected)
+		} else {
+			summary.ExtraNotifications = received - int(expected)
+		}
+		logLiveResults(sub.notifications, summary)
+	}
+
+	if err := liveCleanup(); err != nil {
+		return err
+	}
+
+	log.Println("Live query benchmark finished")
+	return nil
+}
+
+func liveSubscribersDone(subs []*liveSubscriber, expected int64) bool {
+	for _, sub := range subs {
+		if atomic.LoadInt64(&sub.received) < expected {
+			return false
+		}
+	}
+	return true
+}
+
+func newLiveSubscriber(index int) (*liveSubscriber, error) {
+	ws, err := prepareWebsocket()
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := wsSendMessage(context.Background(), ws, 2, `LIVE SELECT * FROM `+liveTable+`;`, nil)
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	liveId, ok := resp[0]["result"].(string)
+	if !ok {
+		ws.Close()
+		return nil, errors.New("unexpected live query response")
+	}
+	// notifications can arrive at any time, so the deadline of the live query request is removed
+	if err := ws.SetDeadline(time.Time{}); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	return &liveSubscriber{
+		index:  index,
+		ws:     ws,
+		liveId: liveId,
+		seen:   make(map[string]struct{}),
+	}, nil
+}
+
+// listen receives notifications until the response to the kill request arrives or the connection fails.
+func (s *liveSubscriber) listen(wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer s.ws.Close()
+	for {
+		response, err := s.ws.Receive()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		receivedAt := time.Now()
+		var msg websocketRawReceive
+		if err := json.Unmarshal(response, &msg); err != nil {
+			log.Println(err)
+			continue
+		}
+		if msg.Id != nil {
+			// the only request sent after the live query is the kill request
+			return
+		}
+
+		var notification liveMessage
+		if err := json.Unmarshal(msg.Result, &notification); err != nil {
+			log.Println(err)
+			continue
+		}
+		if notification.Id != s.liveId {
+			continue
+		}
+		action := strings.ToLower(notification.Action)
+		key := notification.Result.Id + "/" + action + "/" + strconv.Itoa(notification.Result.Seq)
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+		s.seen[key] = struct{}{}
+		atomic.AddInt64(&s.received, 1)
+		s.notifications = append(s.notifications, LiveNotification{
+			Subscriber:          s.index,
+			Action:              action,
+			LatencyMicroSeconds: int(receivedAt.Sub(time.Unix(0, notification.Result.SentAt)).Microseconds()),
+		})
+	}
+}
+
+func (s *liveSubscriber) kill() error {
+	// the response to the kill request has to arrive within the operation timeout, otherwise listen gives up
+	if err := s.ws.SetDeadline(time.Now().Add(operationTimeout)); err != nil {
+		return err
+	}
+	sMsg := WebsocketSend{
+		Id:     3,
+		Method: "kill",
+		Params: []interface{}{s.liveId},
+	}
+	request, err := json.Marshal(sMsg)
+	if err != nil {
+		return err
+	}
+	return s.ws.Send(request)
+}
+
+func liveWriter(wg *sync.WaitGroup, ctx context.Context, writer int, writes *int64) error {
+	ws, err := prepareWebsocket()
+	if err != nil {
+		return err
+	}
+	nextId := 2
+	seq := 0
+	for {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+			return nil
+		default:
+			wg.Add(1)
+
+			start := time.Now()
+			id, stats, err := liveCreate(ctx, ws, nextId, writer, seq)
+			nextId++
+			seq++
+			if err != nil {
+				ws, err = websocketRecover(ctx, ws, "live_create", start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			final := time.Since(start)
+			atomic.AddInt64(writes, 1)
+			logResult("Websocket", "live_create", stats, int(final.Microseconds()))
+
+			start = time.Now()
+			stats, err = liveUpdate(ctx, id, ws, nextId, seq)
+			nextId++
+			seq++
+			if err != nil {
+				ws, err = websocketRecover(ctx, ws, "live_update", start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			final = time.Since(start)
+			atomic.AddInt64(writes, 1)
+			logResult("Websocket", "live_update", stats, int(final.Microseconds()))
+
+			wg.Done()
+		}
+	}
+}
+
+func liveCreate(ctx context.Context, ws wsConn, msgId int, writer int, seq int) (string, opStats, error) {
+	vars := map[string]interface{}{"tb": liveTable, "writer": writer, "seq": seq, "sent_at": time.Now().UnixNano()}
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, liveCreateQuery, vars)
+	if err != nil {
+		return "", stats, err
+	}
+	fullId := resp[0]["result"].([]interface{})[0].(map[string]interface{})["id"].(string)
+	id := strings.Split(fullId, ":")[1]
+	return id, stats, nil
+}
+
+func liveUpdate(ctx context.Context, id string, ws wsConn, msgId int, seq int) (opStats, error) {
+	vars := map[string]interface{}{"tb": liveTable, "id": id, "seq": seq, "sent_at": time.Now().UnixNano()}
+	_, stats, err := wsSendMessage(ctx, ws, msgId, liveUpdateQuery, vars)
+	return stats, err
+}
+
+// liveCleanup removes the live table with the records of the live writers.
+func liveCleanup() error {
+	ws, err := prepareWebsocket()
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	_, _, err = wsSendMessage(context.Background(), ws, 2, `REMOVE TABLE `+liveTable+`;`, nil)
+	return err
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -11,18 +14,102 @@ const (
 	db_name = "benchmark"
 )
 
+// Size of the dataset generated by prepare_db
+const (
+	customerCount = 200000
+	bookCount     = 200000
+	orderCount    = 600000
+)
+
 var (
 	url   = "http://localhost:8000"
 	wsUrl = "ws://localhost:8000/rpc"
+	// operationTimeout is the maximum duration of a single request of any driver
+	operationTimeout = 30 * time.Second
+	// websocketOptions configures the connections of the raw Websocket driver
+	websocketOptions = wsOptions{Library: wsLibraryXnet}
+	// typedDecode decodes the records of the benchmark operations into typed structs instead of maps
+	typedDecode = false
+	// validateResponses enables the checks of validate.go for the responses of the REST, Websocket and SDK phases
+	validateResponses = false
 )
 
 func main() {
 	minutes := flag.Int("minutes", 1, "How many minutes to run each benchmark phase")
 	workers := flag.Int("threads", 1, "How many workers/threads to use for each benchmark phase")
+	liveSubscribers := flag.Int("live-subscribers", 0, "How many LIVE SELECT subscribers to open in the live query phase. The phase is skipped if 0")
+	transactions := flag.Bool("transactions", false, "Run the transaction phase for every connection type")
+	txBooks := flag.Int("tx-books", 100, "How many books the transaction phase orders from. Fewer books cause more conflicts")
+	txRetries := flag.Int("tx-retries", 3, "How many times a conflicting transaction is retried")
+	contention := flag.Bool("contention", false, "Run the write contention phase for every mode and connection type")
+	contentionModeList := flag.String("contention-modes", "increment,read_modify_write,transaction", "Comma separated modes of the contention phase: increment, read_modify_write or transaction")
+	contentionRecords := flag.Int("contention-records", 10, "How many shared records the contention phase increments. Fewer records cause more contention")
+	contentionRetries := flag.Int("contention-retries", 3, "How many times a conflicting increment is retried")
+	graph := flag.Bool("graph", false, "Run the graph traversal phase for every depth and connection type. Requires a dataset with follows and reviewed edges")
+	graphDepths := flag.String("graph-depths", "1,2,3", "Comma separated numbers of follows hops swept by the graph phase")
+	analytics := flag.String("analytics", "", "Run the analytics queries: phase (own phase per connection type), concurrent (next to the CRUD phases) or only (instead of the CRUD phases). Disabled if empty")
+	analyticsWorkerCount := flag.Int("analytics-workers", 1, "How many workers run the analytics queries")
+	analyticsTop := flag.Int("analytics-top", 10, "How many orders and customers the top-N analytics queries return")
+	fullTextSearch := flag.Bool("search", false, "Run the full-text search phase for every connection type. Defines search indexes on the book titles and descriptions and removes them afterwards")
+	vector := flag.Bool("vector", false, "Run the vector search phase for every index and connection type. Requires a dataset with book embeddings")
+	vectorDimension := flag.Int("vector-dimension", 128, "Dimension of the book embeddings, as passed to prepare_db --embedding-dimension")
+	vectorIndexList := flag.String("vector-indexes", "mtree,hnsw", "Comma separated vector indexes the vector phase builds one after the other: mtree or hnsw")
+	vectorK := flag.Int("vector-k", 10, "How many nearest books the vector queries return")
+	vectorQueries := flag.Int("vector-queries", 100, "How many random query vectors the vector phase computes the nearest books of")
+	indexExperiment := flag.String("index-experiment", "", "Comma separated indexes as table.field, with + between the fields of composite indexes, e.g. order.processed,customer.email. Runs the CRUD phases without and with these indexes and compares them. Skipped if empty")
+	pagination := flag.Bool("pagination", false, "Run the pagination phase for every connection type")
+	pageSizes := flag.String("page-sizes", "1,10,100,1000,10000", "Comma separated page sizes swept by the pagination phase")
+	pageOffsets := flag.String("page-offsets", "0,1000,10000,100000,500000", "Comma separated offsets into the orders swept by the pagination phase")
+	documents := flag.Bool("documents", false, "Run the document size phase for every connection type")
+	documentSizes := flag.String("document-sizes", "1024,16384,262144,1048576,4194304", "Comma separated document sizes in bytes with optional weights as size:weight, e.g. 1024:50,1048576:10, created and updated by the document size phase")
+	documentDepth := flag.Int("document-depth", 3, "Number of nested levels of the documents of the document size phase")
+	documentArrayLength := flag.Int("document-array-length", 10, "Length of the array on every level of the documents of the document size phase")
+	batch := flag.Bool("batch", false, "Run the batch insert phase for every connection type")
+	batchSizes := flag.String("batch-sizes", "1,10,100,1000", "Comma separated batch sizes swept by the batch insert phase")
+	importExport := flag.Bool("import-export", false, "Run the import and export phase")
+	importSizes := flag.String("import-sizes", "1000,10000,100000", "Comma separated numbers of records of the datasets imported by the import and export phase")
+	ycsb := flag.String("ycsb", "", "Comma separated YCSB workloads (a to f) to run on every connection type. The phase is skipped if empty")
+	ycsbRecords := flag.Int("ycsb-records", 100000, "How many records the YCSB usertable is loaded with")
+	ycsbFieldCount := flag.Int("ycsb-fields", 10, "How many fields every YCSB record has")
+	ycsbFieldLength := flag.Int("ycsb-field-length", 100, "Length of every YCSB field")
+	ycsbDistribution := flag.String("ycsb-distribution", "", "Key distribution of all YCSB workloads: uniform, zipfian, latest or hotspot. Defaults to the distribution of each workload")
+	ycsbSkew := flag.Float64("ycsb-skew", 0.99, "Zipfian constant of the zipfian and latest distributions, or fraction of the operations on the hot 20% of the keys of the hotspot distribution")
+	keyDistribution := flag.String("key-distribution", "", "Key distribution of the seeded customers and orders that the read, update and join operations access: uniform, zipfian, latest or hotspot. If empty, read and update access the customer the worker created")
+	keySkew := flag.Float64("key-skew", 0.99, "Zipfian constant of the zipfian and latest key distributions, or fraction of the operations on the hot 20% of the keys of the hotspot distribution")
+	sdkMethods := flag.Bool("sdk-methods", false, "Use the SDK methods (Create, Select, Update, Delete) for the CRUD operations of the SDK phase instead of queries. The SDK methods don't report the internal duration")
+	timeout := flag.Duration("timeout", operationTimeout, "Timeout of a single operation. Operations exceeding it are logged with the outcome timeout")
+	typed := flag.Bool("typed-decode", false, "Decode the records of the REST, Websocket and SDK phases into typed structs instead of generic maps. Can't be combined with -validate")
+	validate := flag.Bool("validate", false, "Check that every response of the REST, Websocket and SDK phases contains the expected records. Exits with an error if any check failed")
+	wsLibrary := flag.String("ws-library", wsLibraryXnet, "Websocket library of the raw Websocket driver: xnet (golang.org/x/net/websocket) or gorilla (github.com/gorilla/websocket, also used by the SDK)")
+	wsCompression := flag.Bool("ws-compression", false, "Negotiate permessage-deflate for the raw Websocket driver. Requires -ws-library gorilla")
+	wsCompressionLevel := flag.Int("ws-compression-level", 1, "Compression level of permessage-deflate, from -2 to 9")
+	dialectName := flag.String("dialect", "auto", "Protocol dialect of the server, which sets the namespace and database header names: v1, v2 or auto to pick it from the version reported by /version")
 	flagUrl := flag.String("url", "localhost:8000", "URL of the server to benchmark. Example: localhost:8000 DO NOT INCLUDE THE PROTOCOL")
 	flag.Parse()
+	rand.Seed(time.Now().UnixNano())
 	benchmarkDuration := time.Minute * time.Duration(*minutes)
 	benchmarkWorkers := *workers
+	operationTimeout = *timeout
+	validateResponses = *validate
+	typedDecode = *typed
+	if *keyDistribution != "" {
+		var err error
+		seededKeys, err = newSeededAccess(*keyDistribution, *keySkew)
+		if err != nil {
+			log.Fatalf("Invalid key distribution: %v", err)
+		}
+	}
+	if validateResponses && typedDecode {
+		log.Fatalf("-validate can't be combined with -typed-decode")
+	}
+	analyticsCfg := analyticsConfig{mode: *analytics, workers: *analyticsWorkerCount, top: *analyticsTop}
+	if err := analyticsCfg.validate(); err != nil {
+		log.Fatalf("Invalid analytics options: %v", err)
+	}
+	websocketOptions = wsOptions{Library: *wsLibrary, Compression: *wsCompression, CompressionLevel: *wsCompressionLevel}
+	if err := websocketOptions.validate(); err != nil {
+		log.Fatalf("Invalid websocket options: %v", err)
+	}
 	url = "http://" + *flagUrl
 	wsUrl = "ws://" + *flagUrl + "/rpc"
 
@@ -34,6 +121,20 @@ func main() {
 	}
 	log.Println("Surreal healthcheck passed")
 
+	version, err := fetchVersion()
+	if err != nil {
+		log.Fatalf("Failed to fetch the server version: %v", err)
+	}
+	if *dialectName == "auto" {
+		serverDialect, err = dialectForVersion(version)
+	} else {
+		serverDialect, err = dialectByName(*dialectName)
+	}
+	if err != nil {
+		log.Fatalf("Failed to pick the protocol dialect: %v", err)
+	}
+	log.Printf("Benchmarking %s with dialect %s", version, serverDialect.Name)
+
 	// creates the database if it doesn't exist, deletes old data if they exist
 	err = resultDbInit()
 	if err != nil {
@@ -41,20 +142,243 @@ func main() {
 	}
 	log.Println("Results database initialized")
 
-	err = runRestBenchmark(benchmarkDuration, benchmarkWorkers)
+	runId, err := logRunMetadata(RunMetadata{
+		WebsocketLibrary:          websocketOptions.Library,
+		WebsocketCompression:      websocketOptions.Compression,
+		WebsocketCompressionLevel: websocketOptions.CompressionLevel,
+		TypedDecode:               typedDecode,
+		KeyDistribution:           *keyDistribution,
+		KeySkew:                   *keySkew,
+		ServerVersion:             version,
+		Dialect:                   serverDialect.Name,
+	})
 	if err != nil {
-		log.Fatalf("REST benchmark failed: %v", err)
+		log.Fatalf("Failed to save run metadata: %v", err)
 	}
 
-	err = runWebsocketBenchmark(benchmarkDuration, benchmarkWorkers)
+	err = captureQueryPlans(runId)
 	if err != nil {
-		log.Fatalf("Websocket benchmark failed: %v", err)
+		log.Fatalf("Failed to capture query plans: %v", err)
 	}
 
-	err = runSdkBenchmark(benchmarkDuration, benchmarkWorkers)
-	if err != nil {
-		log.Fatalf("SDK benchmark failed: %v", err)
+	if analyticsCfg.mode != analyticsOnly {
+		stopAnalytics := analyticsCfg.alongside("REST")
+		err = runRestBenchmark(benchmarkDuration, benchmarkWorkers)
+		stopAnalytics()
+		if err != nil {
+			log.Fatalf("REST benchmark failed: %v", err)
+		}
+
+		stopAnalytics = analyticsCfg.alongside("Websocket")
+		err = runWebsocketBenchmark(benchmarkDuration, benchmarkWorkers)
+		stopAnalytics()
+		if err != nil {
+			log.Fatalf("Websocket benchmark failed: %v", err)
+		}
+
+		stopAnalytics = analyticsCfg.alongside("SDK")
+		err = runSdkBenchmark(benchmarkDuration, benchmarkWorkers, *sdkMethods)
+		stopAnalytics()
+		if err != nil {
+			log.Fatalf("SDK benchmark failed: %v", err)
+		}
+
+		if seededKeys != nil {
+			err = seededCleanup()
+			if err != nil {
+				log.Fatalf("Failed to clean up the updated customers: %v", err)
+			}
+		}
+	}
+
+	if analyticsCfg.mode == analyticsPhase || analyticsCfg.mode == analyticsOnly {
+		err = runAnalyticsBenchmark(benchmarkDuration, analyticsCfg)
+		if err != nil {
+			log.Fatalf("Analytics benchmark failed: %v", err)
+		}
+	}
+
+	if *transactions {
+		err = runTransactionBenchmark(benchmarkDuration, benchmarkWorkers, transactionConfig{books: *txBooks, retries: *txRetries})
+		if err != nil {
+			log.Fatalf("Transaction benchmark failed: %v", err)
+		}
+	}
+
+	if *contention {
+		modes, err := parseContentionModes(*contentionModeList)
+		if err != nil {
+			log.Fatalf("Invalid contention modes: %v", err)
+		}
+		if *contentionRecords < 1 {
+			log.Fatalf("Invalid contention configuration: records have to be positive")
+		}
+		err = runContentionBenchmark(benchmarkDuration, benchmarkWorkers, modes, contentionConfig{records: *contentionRecords, retries: *contentionRetries})
+		if err != nil {
+			log.Fatalf("Contention benchmark failed: %v", err)
+		}
+	}
+
+	if *graph {
+		depths, err := parseSizes(*graphDepths)
+		if err != nil {
+			log.Fatalf("Invalid graph depths: %v", err)
+		}
+		err = runGraphBenchmark(benchmarkDuration, benchmarkWorkers, depths)
+		if err != nil {
+			log.Fatalf("Graph benchmark failed: %v", err)
+		}
+	}
+
+	if *fullTextSearch {
+		err = runSearchBenchmark(benchmarkDuration, benchmarkWorkers)
+		if err != nil {
+			log.Fatalf("Search benchmark failed: %v", err)
+		}
+	}
+
+	if *vector {
+		indexes, err := parseVectorIndexes(*vectorIndexList)
+		if err != nil {
+			log.Fatalf("Invalid vector indexes: %v", err)
+		}
+		if *vectorDimension < 1 || *vectorK < 1 || *vectorK > queryLimit || *vectorQueries < 1 {
+			log.Fatalf("Invalid vector configuration: dimension and queries have to be positive and k between 1 and %d", queryLimit)
+		}
+		err = runVectorBenchmark(benchmarkDuration, benchmarkWorkers, vectorConfig{dimension: *vectorDimension, k: *vectorK, queries: *vectorQueries, indexes: indexes})
+		if err != nil {
+			log.Fatalf("Vector benchmark failed: %v", err)
+		}
+	}
+
+	if *indexExperiment != "" {
+		indexes, err := parseExperimentIndexes(*indexExperiment)
+		if err != nil {
+			log.Fatalf("Invalid experiment indexes: %v", err)
+		}
+		err = runIndexExperiment(benchmarkDuration, benchmarkWorkers, *sdkMethods, indexes)
+		if err != nil {
+			log.Fatalf("Index experiment failed: %v", err)
+		}
+	}
+
+	if *pagination {
+		sizes, err := parseSizes(*pageSizes)
+		if err != nil {
+			log.Fatalf("Invalid page sizes: %v", err)
+		}
+		offsets, err := parseOffsets(*pageOffsets)
+		if err != nil {
+			log.Fatalf("Invalid page offsets: %v", err)
+		}
+		err = runPaginationBenchmark(benchmarkDuration, benchmarkWorkers, sizes, offsets)
+		if err != nil {
+			log.Fatalf("Pagination benchmark failed: %v", err)
+		}
+	}
+
+	if *documents {
+		buckets, err := parseDocumentSizes(*documentSizes)
+		if err != nil {
+			log.Fatalf("Invalid document sizes: %v", err)
+		}
+		if *documentDepth < 1 || *documentArrayLength < 1 {
+			log.Fatalf("Document depth and array length have to be at least 1")
+		}
+		err = runDocumentBenchmark(benchmarkDuration, benchmarkWorkers, documentConfig{buckets: buckets, depth: *documentDepth, arrayLength: *documentArrayLength})
+		if err != nil {
+			log.Fatalf("Document benchmark failed: %v", err)
+		}
+	}
+
+	if *batch {
+		sizes, err := parseSizes(*batchSizes)
+		if err != nil {
+			log.Fatalf("Invalid batch sizes: %v", err)
+		}
+		err = runBatchBenchmark(benchmarkDuration, benchmarkWorkers, sizes)
+		if err != nil {
+			log.Fatalf("Batch insert benchmark failed: %v", err)
+		}
+	}
+
+	if *importExport {
+		sizes, err := parseSizes(*importSizes)
+		if err != nil {
+			log.Fatalf("Invalid import sizes: %v", err)
+		}
+		err = runImportExportBenchmark(sizes)
+		if err != nil {
+			log.Fatalf("Import/Export benchmark failed: %v", err)
+		}
+	}
+
+	if *ycsb != "" {
+		workloads, err := parseYcsbWorkloads(*ycsb)
+		if err != nil {
+			log.Fatalf("Invalid YCSB workloads: %v", err)
+		}
+		if *ycsbRecords < 1 || *ycsbFieldCount < 1 || *ycsbFieldLength < 1 {
+			log.Fatalf("Invalid YCSB configuration: records, fields and field length have to be positive")
+		}
+		cfg := ycsbConfig{
+			records:      *ycsbRecords,
+			fields:       *ycsbFieldCount,
+			fieldLength:  *ycsbFieldLength,
+			distribution: *ycsbDistribution,
+			skew:         *ycsbSkew,
+		}
+		err = runYcsbBenchmark(benchmarkDuration, benchmarkWorkers, workloads, cfg)
+		if err != nil {
+			log.Fatalf("YCSB benchmark failed: %v", err)
+		}
+	}
+
+	if *liveSubscribers > 0 {
+		err = runLiveBenchmark(benchmarkDuration, benchmarkWorkers, *liveSubscribers)
+		if err != nil {
+			log.Fatalf("Live query benchmark failed: %v", err)
+		}
+	}
+
+	if err := logThroughputReport(); err != nil {
+		log.Printf("Throughput report failed: %v", err)
+	}
+
+	if validateResponses {
+		failures, err := countOutcome(outcomeInvalid)
+		if err != nil {
+			log.Fatalf("Failed to count correctness failures: %v", err)
+		}
+		if failures > 0 {
+			log.Fatalf("Validation failed: %d operations returned incorrect results", failures)
+		}
+		log.Println("Validation passed")
 	}
 
 	log.Println("Benchmark finished")
 }
+
+// runPhase runs worker on workers goroutines until duration has passed.
+func runPhase(name string, duration time.Duration, workers int, worker func(*sync.WaitGroup, context.Context) error) {
+	log.Printf("Starting %s benchmark with %d workers for %v \n", name, workers, duration)
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), duration)
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+		go worker(wg, ctx)
+	}
+
+	for range ctx.Done() {
+		log.Printf("%s benchmark timeout. Stopping workers. \n", name)
+		break
+	}
+	wg.Wait()
+	ctxCancel()
+
+	log.Printf("%s benchmark finished \n", name)
+}
+
+// queryExecutor sends a query template with the driver of a worker.
+type queryExecutor func(query string, vars map[string]interface{}) ([]map[string]interface{}, opStats, error)
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pagination modes, logged as query type pagination_<mode>. offset skips the first records with START, id_range starts
+// the page at a record id with a range and created_at continues after the created_at of the last record of the
+// previous page, with the orders sorted by created_at.
+const (
+	paginationOffset    = "offset"
+	paginationIdRange   = "id_range"
+	paginationCreatedAt = "created_at"
+)
+
+var paginationModes = []string{paginationOffset, paginationIdRange, paginationCreatedAt}
+
+const (
+	paginationOffsetQuery    = `SELECT * FROM order LIMIT $limit START $start;`
+	paginationCreatedAtQuery = `SELECT * FROM order WHERE created_at > <datetime> $cursor ORDER BY created_at LIMIT $limit;`
+)
+
+// paginationIdRangeQuery returns the query of a page of size orders from the order with the id offset on.
+func paginationIdRangeQuery(offset int, size int) string {
+	return `SELECT * FROM ` + recordRange("order", int64(offset)) + ` LIMIT ` + strconv.Itoa(size) + `;`
+}
+
+// parseOffsets parses a comma separated list of offsets, e.g. "0,1000,100000".
+func parseOffsets(list string) ([]int, error) {
+	var offsets []int
+	for _, field := range strings.Split(list, ",") {
+		offset, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if offset < 0 || offset >= orderCount {
+			return nil, fmt.Errorf("invalid offset %d", offset)
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+// paginationPhase sweeps every page size at every offset. cursors are the created_at of the order at every offset in
+// the created_at order.
+type paginationPhase struct {
+	sizes   []int
+	offsets []int
+	cursors map[int]interface{}
+}
+
+// runPaginationBenchmark runs the pagination modes with random page sizes and offsets on every connection type.
+func runPaginationBenchmark(duration time.Duration, workers int, sizes []int, offsets []int) error {
+	log.Printf("Starting Pagination benchmark with %d workers for %d minutes per connection type \n", workers, int(duration.Minutes()))
+
+	phase := &paginationPhase{sizes: sizes, offsets: offsets, cursors: make(map[int]interface{})}
+	for _, offset := range offsets {
+		cursor, err := createdAtCursor(offset)
+		if err != nil {
+			return err
+		}
+		phase.cursors[offset] = cursor
+	}
+
+	for _, connection := range []string{"REST", "Websocket", "SDK"} {
+		runPhase("Pagination "+connection, duration, workers, runQueryWorkers(connection, false, phase.run))
+	}
+
+	if err := logPaginationReport(); err != nil {
+		return err
+	}
+
+	log.Println("Pagination benchmark finished")
+	return nil
+}
+
+// createdAtCursor returns the created_at of the order at offset in the created_at order. It sorts all orders, so it
+// isn't bounded by the operation timeout.
+func createdAtCursor(offset int) (interface{}, error) {
+	resp, err := unboundedQuery(fmt.Sprintf(`SELECT VALUE created_at FROM order ORDER BY created_at LIMIT 1 START %d;`, offset))
+	if err != nil {
+		return nil, err
+	}
+	cursors, _ := statementResult(resp).([]interface{})
+	if len(cursors) == 0 {
+		return nil, fmt.Errorf("offset %d is beyond the last order", offset)
+	}
+	return cursors[0], nil
+}
+
+// page runs a random mode with a random page size at a random offset.
+func (p *paginationPhase) page(exec queryExecutor) (string, int, int, opStats, error) {
+	mode := paginationModes[rand.Intn(len(paginationModes))]
+	size := p.sizes[rand.Intn(len(p.sizes))]
+	offset := p.offsets[rand.Intn(len(p.offsets))]
+
+	var resp []map[string]interface{}
+	var stats opStats
+	var err error
+	switch mode {
+	case paginationOffset:
+		resp, stats, err = exec(paginationOffsetQuery, map[string]interface{}{"limit": size, "start": offset})
+	case paginationIdRange:
+		resp, stats, err = exec(paginationIdRangeQuery(offset, size), map[string]interface{}{})
+	default:
+		resp, stats, err = exec(paginationCreatedAtQuery, map[string]interface{}{"limit": size, "cursor": p.cursors[offset]})
+	}
+	if err == nil && validateResponses {
+		err = validatePage(statementResult(resp), size)
+	}
+	return mode, size, offset, stats, err
+}
+
+// validatePage checks that a page has at most size orders.
+func validatePage(result interface{}, size int) error {
+	orders, err := recordList(result)
+	if err != nil {
+		return err
+	}
+	if len(orders) > size {
+		return invalid("expected at most %d orders, got %d", size, len(orders))
+	}
+	for _, order := range orders {
+		fields, ok := order.(map[string]interface{})
+		if !ok {
+			return invalid("unexpected record %T", order)
+		}
+		if err := hasFields("id", "created_at")(fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs a random page and logs it labelled with its size and offset.
+func (p *paginationPhase) run(w queryWorker) (string, time.Time, error) {
+	start := time.Now()
+	mode, size, offset, stats, err := p.page(w.exec)
+	if err != nil {
+		return "pagination_" + mode, start, err
+	}
+	res := w.result("pagination_"+mode, stats, start)
+	res.PageSize = size
+	res.PageOffset = offset
+	saveResult(res)
+	return "", start, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// explainedQuery is the select template of a benchmark operation and the variables it's explained with.
+type explainedQuery struct {
+	queryType string
+	query     string
+	vars      func() map[string]interface{}
+}
+
+// explainedQueries are the select templates of the benchmark operations by query type. EXPLAIN is only supported by
+// SELECT statements, so the other operations don't have a plan.
+var explainedQueries = []explainedQuery{
+	{"read", readQuery, func() map[string]interface{} { return recordVars("0") }},
+	{"select", selectQuery, limitVars},
+	{"query", simpleQuery, func() map[string]interface{} { return processedVars(false) }},
+	{"join_relation", joinRelationQuery, func() map[string]interface{} { return processedVars(true) }},
+	{"join_graph", joinGraphQuery, func() map[string]interface{} { return processedVars(true) }},
+}
+
+// seededExplainedQueries are the select templates of the operations on seeded records, which replace read and the
+// joins when a key distribution is set.
+var seededExplainedQueries = []explainedQuery{
+	{"read", readQuery, func() map[string]interface{} { return seededVars("customer", 0) }},
+	{"select", selectQuery, limitVars},
+	{"query", simpleQuery, func() map[string]interface{} { return processedVars(false) }},
+	{"join_relation", seededJoinRelationQuery, func() map[string]interface{} { return seededVars("order", 0) }},
+	{"join_graph", seededJoinGraphQuery, func() map[string]interface{} { return seededVars("order", 0) }},
+}
+
+// Modes of a query plan. EXPLAIN FULL also executes the query and reports the number of fetched records.
+var explainModes = []string{"EXPLAIN", "EXPLAIN FULL"}
+
+// captureQueryPlans stores the plan of every select template of the active key access in every mode. A mode the server
+// doesn't support is logged and stored with the error instead of the plan.
+func captureQueryPlans(runId int) error {
+	queries := explainedQueries
+	if seededKeys != nil {
+		queries = seededExplainedQueries
+	}
+	for _, explained := range queries {
+		for _, mode := range explainModes {
+			query := strings.TrimSuffix(explained.query, ";") + " " + mode + ";"
+			plan := QueryPlan{RunID: runId, QueryType: explained.queryType, Mode: mode, Query: explained.query}
+			resp, _, err := doQuery(context.Background(), query, explained.vars(), new(restTrace))
+			if err != nil {
+				if !isQueryError(err) {
+					return err
+				}
+				log.Printf("%s of %s failed: %v \n", mode, explained.queryType, err)
+				plan.Error = err.Error()
+			} else {
+				encoded, err := json.Marshal(resp[0]["result"])
+				if err != nil {
+					return err
+				}
+				plan.Plan = string(encoded)
+			}
+			logQueryPlan(plan)
+		}
+	}
+	log.Println("Query plans captured")
+	return nil
+}
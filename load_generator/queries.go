@@ -0,0 +1,73 @@
+package main
+
+import "fmt"
+
+// Query templates of the benchmark operations. The parameters are bound by every driver with its own mechanism:
+// query string variables for REST, the second query parameter for Websocket and the vars map for the SDK.
+const (
+	createQuery       = `CREATE type::table($tb) CONTENT $content;`
+	readQuery         = `SELECT * FROM type::thing($tb, $id);`
+	updateQuery       = `UPDATE type::thing($tb, $id) SET email = $email;`
+	deleteQuery       = `DELETE type::thing($tb, $id);`
+	selectQuery       = `SELECT * FROM order LIMIT $limit;`
+	simpleQuery       = `SELECT * FROM order WHERE processed IS $processed LIMIT $limit;`
+	joinRelationQuery = `SELECT books.title FROM order WHERE processed IS $processed LIMIT $limit;`
+	joinGraphQuery    = `SELECT <-ordered<-customer.first_name FROM order WHERE processed IS $processed LIMIT $limit;`
+)
+
+// transactionQuery places an order for a customer and book and decrements the stock of the book.
+// The orders are marked with benchmark = true, so they can be removed after the benchmark.
+const transactionQuery = `BEGIN TRANSACTION;
+LET $ordered = type::thing("order", $order);
+LET $customer_record = type::thing("customer", $customer);
+LET $book_record = type::thing("book", $book);
+CREATE $ordered SET created_at = time::now(), processed = false, books = [$book_record], benchmark = true RETURN NONE;
+RELATE $customer_record->ordered->$ordered RETURN NONE;
+UPDATE $book_record SET stock -= 1 RETURN NONE;
+COMMIT TRANSACTION;`
+
+// Writes of the live query phase, sent_at is the time the write was sent in nanoseconds since the epoch.
+const (
+	liveCreateQuery = `CREATE type::table($tb) SET writer = $writer, seq = $seq, sent_at = $sent_at;`
+	liveUpdateQuery = `UPDATE type::thing($tb, $id) SET seq = $seq, sent_at = $sent_at;`
+)
+
+// recordRange returns the records of table from the id from on, as source of a SELECT. Record id ranges can't be bound
+// as variables, so the queries that scan them are built for every range.
+func recordRange(table string, from int64) string {
+	return fmt.Sprintf("%s:%d..", table, from)
+}
+
+// queryLimit is the number of records returned by the select, query and join operations.
+const queryLimit = 1000
+
+// testCustomer is the customer created by the create operation of every driver.
+func testCustomer() map[string]interface{} {
+	return map[string]interface{}{
+		"first_name": "Test",
+		"last_name":  "Tester",
+		"email":      "test@test.com",
+		"country":    "Germany",
+		"last_login": "2024-02-03T21:31:22+0000",
+	}
+}
+
+func createVars() map[string]interface{} {
+	return map[string]interface{}{"tb": "customer", "content": testCustomer()}
+}
+
+func recordVars(id string) map[string]interface{} {
+	return map[string]interface{}{"tb": "customer", "id": id}
+}
+
+func updateVars(id string) map[string]interface{} {
+	return map[string]interface{}{"tb": "customer", "id": id, "email": "test2@test.com"}
+}
+
+func limitVars() map[string]interface{} {
+	return map[string]interface{}{"limit": queryLimit}
+}
+
+func processedVars(processed bool) map[string]interface{} {
+	return map[string]interface{}{"processed": processed, "limit": queryLimit}
+}
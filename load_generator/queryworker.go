@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// queryWorker sends the queries of an operation with the driver of a worker. ctx is the context exec sends the queries
+// with, trace is only set for REST.
+type queryWorker struct {
+	connection string
+	ctx        context.Context
+	exec       queryExecutor
+	trace      *restTrace
+}
+
+// result returns the result of a successful query that started at start.
+func (w queryWorker) result(query string, stats opStats, start time.Time) Result {
+	final := time.Since(start)
+	res := newResult(w.connection, query, stats, int(final.Microseconds()))
+	if w.trace != nil {
+		res = res.withTrace(w.trace)
+	}
+	return res
+}
+
+// queryOperation runs an operation of a phase and saves its results. If a query fails, it returns its query type and
+// start, so the worker logs the failure and recovers.
+type queryOperation func(w queryWorker) (string, time.Time, error)
+
+// runQueryWorkers returns the worker function of a phase that runs op on connection until the phase ends. If detached
+// is set, the queries are sent without the context of the phase, so the operations in flight at the end of the phase
+// are completed.
+func runQueryWorkers(connection string, detached bool, op queryOperation) func(*sync.WaitGroup, context.Context) error {
+	return func(wg *sync.WaitGroup, ctx context.Context) error {
+		sendCtx := ctx
+		if detached {
+			sendCtx = context.Background()
+		}
+		switch connection {
+		case "REST":
+			return restQueryWorker(wg, ctx, sendCtx, op)
+		case "Websocket":
+			return websocketQueryWorker(wg, ctx, sendCtx, op)
+		default:
+			return sdkQueryWorker(wg, ctx, sendCtx, op)
+		}
+	}
+}
+
+func restQueryWorker(wg *sync.WaitGroup, ctx context.Context, sendCtx context.Context, op queryOperation) error {
+	trace := new(restTrace)
+	w := queryWorker{connection: "REST", ctx: sendCtx, trace: trace}
+	w.exec = func(query string, vars map[string]interface{}) ([]map[string]interface{}, opStats, error) {
+		return doQuery(sendCtx, query, vars, trace)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			wg.Add(1)
+
+			query, start, err := op(w)
+			if err != nil {
+				wg.Done()
+				if restRecover(ctx, query, start, err) {
+					continue
+				}
+				return err
+			}
+
+			wg.Done()
+		}
+	}
+}
+
+func websocketQueryWorker(wg *sync.WaitGroup, ctx context.Context, sendCtx context.Context, op queryOperation) error {
+	ws, err := prepareWebsocket()
+	if err != nil {
+		return err
+	}
+	nextId := 2
+	w := queryWorker{connection: "Websocket", ctx: sendCtx}
+	w.exec = func(query string, vars map[string]interface{}) ([]map[string]interface{}, opStats, error) {
+		id := nextId
+		nextId++
+		return wsSendMessage(sendCtx, ws, id, query, vars)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+			return nil
+		default:
+			wg.Add(1)
+
+			query, start, err := op(w)
+			if err != nil {
+				ws, err = websocketRecover(ctx, ws, query, start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			wg.Done()
+		}
+	}
+}
+
+func sdkQueryWorker(wg *sync.WaitGroup, ctx context.Context, sendCtx context.Context, op queryOperation) error {
+	db, err := prepareSdk()
+	if err != nil {
+		return err
+	}
+	w := queryWorker{connection: "SDK", ctx: sendCtx}
+	w.exec = func(query string, vars map[string]interface{}) ([]map[string]interface{}, opStats, error) {
+		return sdkQuery(sendCtx, db, query, vars)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil
+		default:
+			wg.Add(1)
+
+			query, start, err := op(w)
+			if err != nil {
+				db, err = sdkRecover(ctx, db, query, start, err)
+				wg.Done()
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			wg.Done()
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/surrealdb/surrealdb.go"
+)
+
+const (
+	reconnectMinBackoff = 100 * time.Millisecond
+	reconnectMaxBackoff = 5 * time.Second
+)
+
+// reconnect calls connect with exponential backoff until it succeeds or ctx is done. connect has to replay the
+// complete handshake of the connection. The reconnection and the time it took are logged to the results.
+func reconnect[C any](ctx context.Context, connection string, connect func() (C, error)) (C, error) {
+	start := time.Now()
+	backoff := reconnectMinBackoff
+	for attempts := 1; ; attempts++ {
+		conn, err := connect()
+		if err == nil {
+			downtime := time.Since(start)
+			log.Printf("%s worker reconnected after %d attempts and %v \n", connection, attempts, downtime)
+			logReconnect(connection, attempts, int(downtime.Microseconds()))
+			return conn, nil
+		}
+		log.Printf("%s worker failed to reconnect: %v \n", connection, err)
+
+		select {
+		case <-ctx.Done():
+			var zero C
+			return zero, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
+}
+
+// logFailure logs the operation that was in flight when err occurred, as timeout if it exceeded the operation timeout,
+// as invalid if its response failed validation and as failed otherwise. Operations interrupted by the end of the phase
+// are not logged.
+func logFailure(ctx context.Context, connection string, query string, start time.Time, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	outcome := outcomeFailed
+	if isTimeout(err) {
+		outcome = outcomeTimeout
+	} else if isInvalid(err) {
+		outcome = outcomeInvalid
+	}
+	log.Printf("%s %s failed: %v \n", connection, query, err)
+	saveResult(newResult(connection, query, opStats{InternalDuration: -1, EncodeDuration: -1, DecodeDuration: -1}, int(time.Since(start).Microseconds())).withOutcome(outcome))
+}
+
+// restRecover logs the failed operation and reports whether the worker can continue. REST workers continue after
+// timeouts, failed validations and at the end of the phase, but stop on any other error.
+func restRecover(ctx context.Context, query string, start time.Time, err error) bool {
+	logFailure(ctx, "REST", query, start, err)
+	return ctx.Err() != nil || isTimeout(err) || isInvalid(err)
+}
+
+// websocketRecover logs the failed operation and reconnects, unless the query itself or its validation failed and the
+// connection is still usable. A connection that timed out is always replaced.
+func websocketRecover(ctx context.Context, ws wsConn, query string, start time.Time, err error) (wsConn, error) {
+	logFailure(ctx, "Websocket", query, start, err)
+	var qErr *queryError
+	if errors.As(err, &qErr) || isInvalid(err) {
+		return ws, nil
+	}
+	ws.Close()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return reconnect(ctx, "Websocket", prepareWebsocket)
+}
+
+// sdkRecover is the SDK equivalent of websocketRecover.
+func sdkRecover(ctx context.Context, db *surrealdb.DB, query string, start time.Time, err error) (*surrealdb.DB, error) {
+	logFailure(ctx, "SDK", query, start, err)
+	var qErr *queryError
+	if errors.As(err, &qErr) || isInvalid(err) {
+		return db, nil
+	}
+	db.Close()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return reconnect(ctx, "SDK", prepareSdk)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// queryError is returned when one of the statements of a query did not finish with status OK.
+type queryError struct {
+	Detail string
+}
+
+func (e *queryError) Error() string {
+	if e.Detail == "" {
+		return "status not OK"
+	}
+	return "status not OK: " + e.Detail
+}
+
+// checkStatus returns a queryError for the first statement that did not finish with status OK. The error message
+// of a failed statement is its result, older releases reported it as detail.
+func checkStatus(result []map[string]interface{}) error {
+	for _, statement := range result {
+		if statement["status"] != "OK" {
+			detail, ok := statement["result"].(string)
+			if !ok {
+				detail, _ = statement["detail"].(string)
+			}
+			return &queryError{Detail: detail}
+		}
+	}
+	return nil
+}
+
+// isQueryError reports whether err is caused by a statement that did not finish with status OK.
+func isQueryError(err error) bool {
+	var qErr *queryError
+	return errors.As(err, &qErr)
+}
+
+// isConflict reports whether err is caused by a transaction that failed due to a read or write conflict.
+func isConflict(err error) bool {
+	var qErr *queryError
+	if !errors.As(err, &qErr) {
+		return false
+	}
+	detail := strings.ToLower(qErr.Detail)
+	return strings.Contains(detail, "conflict") || strings.Contains(detail, "can be retried")
+}
+
+// isAlreadyExists reports whether err is caused by creating a record that already exists.
+func isAlreadyExists(err error) bool {
+	var qErr *queryError
+	return errors.As(err, &qErr) && strings.Contains(strings.ToLower(qErr.Detail), "already exists")
+}
+
+// isTimeout reports whether err is caused by an exceeded deadline.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// opStats describes a single operation as seen by the driver.
+type opStats struct {
+	// InternalDuration is the server side duration of all statements in microseconds, -1 if the server doesn't report it
+	InternalDuration int
+	RequestBytes     int
+	ResponseBytes    int
+	// Records is the number of records returned by all statements
+	Records int
+	// EncodeDuration and DecodeDuration are the client side durations of encoding the request and decoding the
+	// response in microseconds, -1 if the driver can't measure them
+	EncodeDuration int
+	DecodeDuration int
+	// messageSizes estimates RequestBytes and ResponseBytes if the driver can't count them. It's only called by
+	// newResult, so the estimate isn't part of the timed operation.
+	messageSizes func() (int, int)
+}
+
+// add returns the stats of two operations sent as one, durations that are unknown for either of them stay unknown.
+func (s opStats) add(other opStats) opStats {
+	return opStats{
+		InternalDuration: addKnown(s.InternalDuration, other.InternalDuration),
+		RequestBytes:     s.RequestBytes + other.RequestBytes,
+		ResponseBytes:    s.ResponseBytes + other.ResponseBytes,
+		Records:          s.Records + other.Records,
+		EncodeDuration:   addKnown(s.EncodeDuration, other.EncodeDuration),
+		DecodeDuration:   addKnown(s.DecodeDuration, other.DecodeDuration),
+		messageSizes:     addSizes(s.messageSizes, other.messageSizes),
+	}
+}
+
+// addSizes returns the summed estimates of two operations, nil if neither of them is estimated.
+func addSizes(a func() (int, int), b func() (int, int)) func() (int, int) {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return func() (int, int) {
+		requestA, responseA := a()
+		requestB, responseB := b()
+		return requestA + requestB, responseA + responseB
+	}
+}
+
+// sizes returns the request and response bytes, estimating them if the driver couldn't count them.
+func (s opStats) sizes() (int, int) {
+	if s.messageSizes == nil {
+		return s.RequestBytes, s.ResponseBytes
+	}
+	request, response := s.messageSizes()
+	return s.RequestBytes + request, s.ResponseBytes + response
+}
+
+// addKnown adds two durations, -1 if either of them is unknown.
+func addKnown(a int, b int) int {
+	if a < 0 || b < 0 {
+		return -1
+	}
+	return a + b
+}
+
+// statementStats sums up the server side duration and the number of records of all statements of a query.
+func statementStats(result []map[string]interface{}) (opStats, error) {
+	var stats opStats
+	var total time.Duration
+	for _, statement := range result {
+		value, ok := statement["time"].(string)
+		if !ok {
+			return stats, errors.New("statement result without time")
+		}
+		internalDur, err := time.ParseDuration(value)
+		if err != nil {
+			return stats, err
+		}
+		total += internalDur
+		stats.Records += countRecords(statement["result"])
+	}
+	stats.InternalDuration = int(total.Microseconds())
+	return stats, nil
+}
+
+// countRecords returns the number of records in the result of a statement.
+func countRecords(result interface{}) int {
+	switch records := result.(type) {
+	case []interface{}:
+		return len(records)
+	case map[string]interface{}:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
+	neturl "net/url"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +39,7 @@ func runRestBenchmark(duration time.Duration, workers int) error {
 }
 
 func restWorker(wg *sync.WaitGroup, ctx context.Context) error {
+	trace := new(restTrace)
 	for {
 		select {
 		case <-ctx.Done():
@@ -44,226 +48,328 @@ func restWorker(wg *sync.WaitGroup, ctx context.Context) error {
 			wg.Add(1)
 
 			start := time.Now()
-			id, dur, err := restCreate()
+			id, stats, err := restCreate(ctx, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "create", start, err) {
+					continue
+				}
 				return err
 			}
 			final := time.Since(start)
-			logResult("REST", "create", dur, int(final.Microseconds()))
+			logRestResult("create", stats, int(final.Microseconds()), trace)
 
 			start = time.Now()
-			dur, err = restRead(id)
+			stats, err = restRead(ctx, id, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "read", start, err) {
+					continue
+				}
 				return err
 			}
 			final = time.Since(start)
-			logResult("REST", "read", dur, int(final.Microseconds()))
+			logRestResult("read", stats, int(final.Microseconds()), trace)
 
 			start = time.Now()
-			dur, err = restUpdate(id)
+			stats, err = restUpdate(ctx, id, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "update", start, err) {
+					continue
+				}
 				return err
 			}
 			final = time.Since(start)
-			logResult("REST", "update", dur, int(final.Microseconds()))
+			logRestResult("update", stats, int(final.Microseconds()), trace)
 
 			start = time.Now()
-			dur, err = restDelete(id)
+			stats, err = restDelete(ctx, id, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "delete", start, err) {
+					continue
+				}
 				return err
 			}
 			final = time.Since(start)
-			logResult("REST", "delete", dur, int(final.Microseconds()))
+			logRestResult("delete", stats, int(final.Microseconds()), trace)
 
 			start = time.Now()
-			dur, err = restSelect()
+			stats, err = restSelect(ctx, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "select", start, err) {
+					continue
+				}
 				return err
 			}
 			final = time.Since(start)
-			logResult("REST", "select", dur, int(final.Microseconds()))
+			logRestResult("select", stats, int(final.Microseconds()), trace)
 
 			start = time.Now()
-			dur, err = restSimpleQuery()
+			stats, err = restSimpleQuery(ctx, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "query", start, err) {
+					continue
+				}
 				return err
 			}
 			final = time.Since(start)
-			logResult("REST", "query", dur, int(final.Microseconds()))
+			logRestResult("query", stats, int(final.Microseconds()), trace)
 
 			start = time.Now()
-			dur, err = restJoinRelation()
+			stats, err = restJoinRelation(ctx, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "join_relation", start, err) {
+					continue
+				}
 				return err
 			}
 			final = time.Since(start)
-			logResult("REST", "join_relation", dur, int(final.Microseconds()))
+			logRestResult("join_relation", stats, int(final.Microseconds()), trace)
 
 			start = time.Now()
-			dur, err = restJoinGraph()
+			stats, err = restJoinGraph(ctx, trace)
 			if err != nil {
 				wg.Done()
-				log.Println(err)
+				if restRecover(ctx, "join_graph", start, err) {
+					continue
+				}
 				return err
 			}
 			final = time.Since(start)
-			logResult("REST", "join_graph", dur, int(final.Microseconds()))
+			logRestResult("join_graph", stats, int(final.Microseconds()), trace)
 
 			wg.Done()
 		}
 	}
 }
 
-func doRequest(method string, path string, body io.Reader) ([]map[string]interface{}, error) {
-	req, err := http.NewRequest(method, url+path, body)
+// restTrace holds the phases of a single HTTP request as observed through httptrace.
+// FirstByte is measured from the moment the request was fully written, so together
+// with the other phases it adds up to roughly the total duration of the request.
+type restTrace struct {
+	Dns              time.Duration
+	Connect          time.Duration
+	Tls              time.Duration
+	FirstByte        time.Duration
+	BodyRead         time.Duration
+	BodyDecode       time.Duration
+	ConnectionReused bool
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	gotFirstByte time.Time
+}
+
+func (t *restTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.Dns = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			t.Connect = time.Since(t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.Tls = time.Since(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.ConnectionReused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			t.gotFirstByte = time.Now()
+			t.FirstByte = t.gotFirstByte.Sub(t.wroteRequest)
+		},
+	}
+}
+
+// doRequest sends a request and returns the statement results decoded with decode. The request is counted as its body,
+// with the content length set by http.NewRequest for the readers used by the benchmark, and its query string, which
+// holds the variables of doQuery. The body is already encoded, so the encode duration is left to the caller.
+func doRequest(ctx context.Context, method string, path string, body io.Reader, decode decoder, trace *restTrace) ([]map[string]interface{}, opStats, error) {
+	*trace = restTrace{}
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()), method, url+path, body)
 	if err != nil {
-		return nil, err
+		return nil, opStats{}, err
 	}
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("NS", db_ns)
-	req.Header.Set("DB", db_name)
+	serverDialect.setHeaders(req)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, opStats{}, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed: %v", resp.Status)
+		return nil, opStats{}, fmt.Errorf("request failed: %v", resp.Status)
 	}
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, opStats{}, err
 	}
+	trace.BodyRead = time.Since(trace.gotFirstByte)
 
-	var result []map[string]interface{}
-	err = json.Unmarshal(bodyBytes, &result)
+	decodeStart := time.Now()
+	result, err := decode(bodyBytes)
 	if err != nil {
-		return nil, err
+		return nil, opStats{}, err
 	}
+	trace.BodyDecode = time.Since(decodeStart)
 	if len(result) == 0 {
-		return nil, errors.New("empty response")
+		return nil, opStats{}, errors.New("empty response")
+	}
+	if err := checkStatus(result); err != nil {
+		return nil, opStats{}, err
 	}
-	if result[0]["status"] != "OK" {
-		return nil, errors.New("status not OK")
+	stats, err := statementStats(result)
+	if err != nil {
+		return nil, opStats{}, err
 	}
-	return result, nil
+	stats.RequestBytes = int(req.ContentLength) + len(req.URL.RawQuery)
+	stats.ResponseBytes = len(bodyBytes)
+	stats.DecodeDuration = int(trace.BodyDecode.Microseconds())
+	return result, stats, nil
 }
 
-func restRead(id string) (int, error) {
-	resp, err := doRequest("GET", "/key/customer/"+id, nil)
-	if err != nil {
-		return 0, err
+// doQuery sends a query template to /sql and binds vars as query string variables. Encoding the variables is
+// measured as encode duration.
+func doQuery(ctx context.Context, query string, vars map[string]interface{}, trace *restTrace) ([]map[string]interface{}, opStats, error) {
+	encodeStart := time.Now()
+	values := neturl.Values{}
+	for name, value := range vars {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, opStats{}, err
+		}
+		values.Set(name, string(encoded))
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	path := "/sql"
+	if len(values) > 0 {
+		path += "?" + values.Encode()
 	}
-	return int(internalDur.Microseconds()), nil
+	encodeDuration := time.Since(encodeStart)
+	resp, stats, err := doRequest(ctx, "POST", path, strings.NewReader(query), queryDecoder(query), trace)
+	stats.EncodeDuration = int(encodeDuration.Microseconds())
+	return resp, stats, err
 }
 
-func restDelete(id string) (int, error) {
-	resp, err := doRequest("DELETE", "/key/customer/"+id, nil)
+// insertRecords inserts records into table. The records are sent as JSON literal in the body of the query, as the
+// variables of doQuery are part of the URL and a batch of records would exceed its maximum length.
+func insertRecords(table string, records []map[string]interface{}) error {
+	encoded, err := json.Marshal(records)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	query := `INSERT INTO ` + table + ` ` + string(encoded) + `;`
+	_, _, err = doRequest(context.Background(), "POST", "/sql", strings.NewReader(query), decodeGeneric, new(restTrace))
+	return err
+}
+
+func restRead(ctx context.Context, id string, trace *restTrace) (opStats, error) {
+	if seededKeys != nil {
+		return restSeededRead(ctx, trace)
+	}
+	resp, stats, err := doRequest(ctx, "GET", "/key/customer/"+id, nil, customerDecoder(), trace)
+	if err == nil && validateResponses {
+		err = validateCustomer(statementResult(resp), "customer:"+id, createdEmail)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func restUpdate(id string) (int, error) {
-	var body = strings.NewReader(`{"email":"test2@test.com"}`)
-	resp, err := doRequest("PATCH", "/key/customer/"+id, body)
-	if err != nil {
-		return 0, err
+// restDelete deletes the customer. In validation mode the customer is read again afterwards, to check that it's gone.
+func restDelete(ctx context.Context, id string, trace *restTrace) (opStats, error) {
+	_, stats, err := doRequest(ctx, "DELETE", "/key/customer/"+id, nil, customerDecoder(), trace)
+	if err != nil || !validateResponses {
+		return stats, err
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
+	resp, _, err := doRequest(ctx, "GET", "/key/customer/"+id, nil, customerDecoder(), new(restTrace))
 	if err != nil {
-		return 0, err
+		return stats, err
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, validateDeleted(statementResult(resp), id)
 }
 
-func restCreate() (string, int, error) {
-	var body = strings.NewReader(`{"first_name":"Test","last_name":"Tester","email":"test@test.com","country":"Germany","last_login":"2024-02-03T21:31:22+0000"}`)
-
-	resp, err := doRequest("POST", "/key/customer", body)
-	if err != nil {
-		return "", 0, err
+func restUpdate(ctx context.Context, id string, trace *restTrace) (opStats, error) {
+	if seededKeys != nil {
+		return restSeededUpdate(ctx, trace)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return "", 0, err
+	var body = strings.NewReader(`{"email":"test2@test.com"}`)
+	resp, stats, err := doRequest(ctx, "PATCH", "/key/customer/"+id, body, customerDecoder(), trace)
+	if err == nil && validateResponses {
+		err = validateCustomer(statementResult(resp), "customer:"+id, updatedEmail)
 	}
-	fullId := resp[0]["result"].([]interface{})[0].(map[string]interface{})["id"].(string)
-	id := strings.Split(fullId, ":")[1]
-	return id, int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func restSelect() (int, error) {
-	var body = strings.NewReader(`SELECT * FROM order LIMIT 1000`)
-	resp, err := doRequest("POST", "/sql", body)
+func restCreate(ctx context.Context, trace *restTrace) (string, opStats, error) {
+	var body = strings.NewReader(`{"first_name":"Test","last_name":"Tester","email":"test@test.com","country":"Germany","last_login":"2024-02-03T21:31:22+0000"}`)
+
+	resp, stats, err := doRequest(ctx, "POST", "/key/customer", body, customerDecoder(), trace)
 	if err != nil {
-		return 0, err
+		return "", stats, err
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	if validateResponses {
+		if err := validateCustomer(statementResult(resp), "", createdEmail); err != nil {
+			return "", stats, err
+		}
 	}
-	return int(internalDur.Microseconds()), nil
+	id := strings.Split(createdId(resp), ":")[1]
+	return id, stats, nil
 }
 
-func restSimpleQuery() (int, error) {
-	var body = strings.NewReader(`SELECT * FROM order WHERE processed IS FALSE LIMIT 1000`)
-	resp, err := doRequest("POST", "/sql", body)
-	if err != nil {
-		return 0, err
+func restSelect(ctx context.Context, trace *restTrace) (opStats, error) {
+	resp, stats, err := doQuery(ctx, selectQuery, limitVars(), trace)
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), selectCheck)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	return stats, err
+}
+
+func restSimpleQuery(ctx context.Context, trace *restTrace) (opStats, error) {
+	resp, stats, err := doQuery(ctx, simpleQuery, processedVars(false), trace)
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), simpleQueryCheck)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func restJoinRelation() (int, error) {
-	var body = strings.NewReader(`SELECT books.title FROM order WHERE processed IS TRUE LIMIT 1000`)
-	resp, err := doRequest("POST", "/sql", body)
-	if err != nil {
-		return 0, err
+func restJoinRelation(ctx context.Context, trace *restTrace) (opStats, error) {
+	if seededKeys != nil {
+		return restSeededJoinRelation(ctx, trace)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	resp, stats, err := doQuery(ctx, joinRelationQuery, processedVars(true), trace)
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), joinRelationCheck)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func restJoinGraph() (int, error) {
-	var body = strings.NewReader(`SELECT <-ordered<-customer.first_name FROM order WHERE processed IS TRUE LIMIT 1000`)
-	resp, err := doRequest("POST", "/sql", body)
-	if err != nil {
-		return 0, err
+func restJoinGraph(ctx context.Context, trace *restTrace) (opStats, error) {
+	if seededKeys != nil {
+		return restSeededJoinGraph(ctx, trace)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	resp, stats, err := doQuery(ctx, joinGraphQuery, processedVars(true), trace)
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), joinGraphCheck)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -13,9 +14,185 @@ type Result struct {
 	ID                           int `gorm:"primaryKey"`
 	ConnectionType               string
 	QueryType                    string
+	Outcome                      string
 	InternalDurationMicroSeconds int
 	TotalDurationMicroSeconds    int
-	CreatedAt                    time.Time `gorm:"autoCreateTime"`
+	RequestBytes                 int
+	ResponseBytes                int
+	// Number of records returned by the operation
+	Records int
+	// Client side durations of encoding the request and decoding the response, -1 if unknown.
+	// For the SDK only the decoding after the SDK returned is measured.
+	EncodeDurationMicroSeconds int
+	DecodeDurationMicroSeconds int
+	// HTTP phases of the request, only recorded for REST. -1 for other connection types.
+	DnsDurationMicroSeconds        int
+	ConnectDurationMicroSeconds    int
+	TlsDurationMicroSeconds        int
+	FirstByteDurationMicroSeconds  int
+	BodyReadDurationMicroSeconds   int
+	BodyDecodeDurationMicroSeconds int
+	ConnectionReused               bool
+	// Number of records sent with a batch insert, 0 for all other operations.
+	BatchSize int
+	// YCSB workload of the operation, empty for all other phases. Failed operations aren't labelled.
+	Workload string
+	// Depth of a graph traversal and out-degree of the customer it started from, 0 for all other operations.
+	// Failed operations aren't labelled.
+	Depth  int
+	FanOut int
+	// Recall of a nearest neighbour query against the ground truth, nil for all other operations.
+	Recall *float64
+	// Index state of the CRUD phases of the index experiment, without_indexes or with_indexes. Empty for all other
+	// operations.
+	IndexState string
+	// Page size and offset of a pagination query, 0 for all other operations. Failed operations aren't labelled.
+	PageSize   int
+	PageOffset int
+	// Document size bucket in bytes of a document operation, 0 for all other operations. Failed operations aren't
+	// labelled.
+	DocumentSize int
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+type LiveNotification struct {
+	ID                  int `gorm:"primaryKey"`
+	Subscriber          int
+	Action              string
+	LatencyMicroSeconds int
+	CreatedAt           time.Time `gorm:"autoCreateTime"`
+}
+
+type LiveSubscriberSummary struct {
+	ID                    int `gorm:"primaryKey"`
+	Subscriber            int
+	ExpectedNotifications int
+	ReceivedNotifications int
+	MissedNotifications   int
+	// Notifications beyond the expected ones, caused by writes that were applied but not acknowledged before the end
+	// of the phase
+	ExtraNotifications int
+	CreatedAt          time.Time `gorm:"autoCreateTime"`
+}
+
+// Outcomes of an operation. Every operation that doesn't report a more specific outcome is logged as ok.
+const (
+	outcomeOk       = "ok"
+	outcomeCommit   = "commit"
+	outcomeConflict = "conflict"
+	outcomeRetry    = "retry"
+	outcomeFailed   = "failed"
+	outcomeTimeout  = "timeout"
+	outcomeInvalid  = "invalid"
+)
+
+type BatchSummary struct {
+	ID             int `gorm:"primaryKey"`
+	ConnectionType string
+	BatchSize      int
+	Requests       int
+	Records        int
+	// Summed duration of the inserts per worker, without the deletes after every insert
+	DurationMicroSeconds int
+	RecordsPerSecond     float64
+	CreatedAt            time.Time `gorm:"autoCreateTime"`
+}
+
+// TransferSummary is a single /import or /export request. Bytes is the size of the imported dataset or the export.
+type TransferSummary struct {
+	ID                   int `gorm:"primaryKey"`
+	Operation            string
+	Records              int
+	Bytes                int
+	DurationMicroSeconds int
+	BytesPerSecond       float64
+	RecordsPerSecond     float64
+	CreatedAt            time.Time `gorm:"autoCreateTime"`
+}
+
+type Reconnect struct {
+	ID                   int `gorm:"primaryKey"`
+	ConnectionType       string
+	Attempts             int
+	DowntimeMicroSeconds int
+	CreatedAt            time.Time `gorm:"autoCreateTime"`
+}
+
+// RunMetadata describes the configuration of the run, there is a single row per results database.
+type RunMetadata struct {
+	ID int `gorm:"primaryKey"`
+	// Library of the raw Websocket driver, the SDK always uses gorilla with write compression
+	WebsocketLibrary          string
+	WebsocketCompression      bool
+	WebsocketCompressionLevel int
+	// Records were decoded into typed structs instead of maps
+	TypedDecode bool
+	// Key distribution and skew of the seeded records accessed by the read, update and join operations, empty if they
+	// access the customer created by the worker
+	KeyDistribution string
+	KeySkew         float64
+	// Version reported by /version, e.g. surrealdb-1.1.1, and the protocol dialect used for it
+	ServerVersion string
+	Dialect       string
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
+
+// ContentionSummary compares the acknowledged increments of a contention phase with the final sum of the counters.
+// LostUpdates is the difference, Conflicts and Retries count the attempts with these outcomes.
+type ContentionSummary struct {
+	ID             int `gorm:"primaryKey"`
+	ConnectionType string
+	Mode           string
+	Records        int
+	Workers        int
+	Increments     int64
+	FinalValue     int64
+	LostUpdates    int64
+	Conflicts      int64
+	Retries        int64
+	Failures       int64
+	Timeouts       int64
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+// IndexBuild is the duration of a DEFINE INDEX statement, which builds the index on the existing records. Error is
+// set if the statement failed.
+type IndexBuild struct {
+	ID                   int `gorm:"primaryKey"`
+	Phase                string
+	Table                string
+	Name                 string
+	Definition           string
+	DurationMicroSeconds int
+	Error                string
+	CreatedAt            time.Time `gorm:"autoCreateTime"`
+}
+
+// IndexExperimentSummary compares the average durations of a query type with and without the indexes of the index
+// experiment. Speedup is the duration without indexes divided by the duration with indexes, below 1 for slowdowns.
+// Write is set for the query types that write records.
+type IndexExperimentSummary struct {
+	ID                            int `gorm:"primaryKey"`
+	ConnectionType                string
+	QueryType                     string
+	AvgWithoutIndexesMicroSeconds float64
+	AvgWithIndexesMicroSeconds    float64
+	Speedup                       float64
+	Write                         bool
+	CreatedAt                     time.Time `gorm:"autoCreateTime"`
+}
+
+// QueryPlan is the plan of a query template reported by EXPLAIN or EXPLAIN FULL, linked to the run by RunID.
+type QueryPlan struct {
+	ID        int `gorm:"primaryKey"`
+	RunID     int
+	QueryType string
+	Mode      string
+	Query     string
+	// JSON encoded plan, empty if the server couldn't explain the query
+	Plan      string
+	Error     string
+	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
 
 const dbName = "results.sqlite"
@@ -36,16 +213,369 @@ func resultDbInit() error {
 	if err != nil {
 		return err
 	}
-	db.AutoMigrate(&Result{})
+	db.AutoMigrate(&Result{}, &LiveNotification{}, &LiveSubscriberSummary{}, &BatchSummary{}, &Reconnect{}, &RunMetadata{}, &TransferSummary{}, &QueryPlan{}, &ContentionSummary{}, &IndexBuild{}, &IndexExperimentSummary{})
+	if err := db.Exec(throughputReportView).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(graphReportView).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(vectorReportView).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(paginationReportView).Error; err != nil {
+		return err
+	}
+	return db.Exec(documentReportView).Error
+}
+
+// throughputReportView summarizes the successful operations per connection and query type. One byte per microsecond
+// is one MB/s, the latency per row is the total duration divided by the number of returned records.
+const throughputReportView = `CREATE VIEW throughput_report AS
+SELECT
+	connection_type,
+	query_type,
+	COUNT(*) AS operations,
+	AVG(total_duration_micro_seconds) AS avg_total_duration_micro_seconds,
+	AVG(NULLIF(encode_duration_micro_seconds, -1)) AS avg_encode_duration_micro_seconds,
+	AVG(NULLIF(decode_duration_micro_seconds, -1)) AS avg_decode_duration_micro_seconds,
+	SUM(request_bytes + response_bytes) AS bytes,
+	SUM(records) AS records,
+	CAST(SUM(request_bytes + response_bytes) AS REAL) / SUM(total_duration_micro_seconds) AS megabytes_per_second,
+	CAST(SUM(total_duration_micro_seconds) AS REAL) / NULLIF(SUM(records), 0) AS micro_seconds_per_row
+FROM results
+WHERE outcome IN ('ok', 'commit', 'retry')
+GROUP BY connection_type, query_type`
+
+// graphReportView summarizes the successful graph traversals per depth and fan-out. The fan-out is bucketed by powers
+// of 10, min_fan_out orders the buckets.
+const graphReportView = `CREATE VIEW graph_report AS
+SELECT
+	connection_type,
+	query_type,
+	depth,
+	CASE
+		WHEN fan_out = 0 THEN '0'
+		WHEN fan_out < 10 THEN '1-9'
+		WHEN fan_out < 100 THEN '10-99'
+		WHEN fan_out < 1000 THEN '100-999'
+		ELSE '1000+'
+	END AS fan_out_bucket,
+	MIN(fan_out) AS min_fan_out,
+	COUNT(*) AS operations,
+	AVG(total_duration_micro_seconds) AS avg_total_duration_micro_seconds,
+	MAX(total_duration_micro_seconds) AS max_total_duration_micro_seconds,
+	AVG(records) AS avg_reached_records
+FROM results
+WHERE outcome = 'ok' AND query_type LIKE 'graph_%'
+GROUP BY connection_type, query_type, depth, fan_out_bucket`
+
+// vectorReportView summarizes the latency and recall of the successful nearest neighbour queries per index.
+const vectorReportView = `CREATE VIEW vector_report AS
+SELECT
+	connection_type,
+	query_type,
+	COUNT(*) AS operations,
+	AVG(total_duration_micro_seconds) AS avg_total_duration_micro_seconds,
+	MAX(total_duration_micro_seconds) AS max_total_duration_micro_seconds,
+	AVG(recall) AS avg_recall,
+	MIN(recall) AS min_recall
+FROM results
+WHERE outcome = 'ok' AND recall IS NOT NULL
+GROUP BY connection_type, query_type`
+
+// paginationReportView summarizes the successful pagination queries per page size and offset.
+const paginationReportView = `CREATE VIEW pagination_report AS
+SELECT
+	connection_type,
+	query_type,
+	page_size,
+	page_offset,
+	COUNT(*) AS operations,
+	AVG(total_duration_micro_seconds) AS avg_total_duration_micro_seconds,
+	MAX(total_duration_micro_seconds) AS max_total_duration_micro_seconds,
+	AVG(records) AS avg_records,
+	CAST(SUM(total_duration_micro_seconds) AS REAL) / NULLIF(SUM(records), 0) AS micro_seconds_per_row
+FROM results
+WHERE outcome = 'ok' AND query_type LIKE 'pagination_%'
+GROUP BY connection_type, query_type, page_size, page_offset`
+
+// documentReportView summarizes the latency and throughput of the successful document operations per size bucket.
+// The documents per second depend on the duration of the phase, so they are computed by logDocumentReport.
+const documentReportView = `CREATE VIEW document_report AS
+SELECT
+	connection_type,
+	query_type,
+	document_size,
+	COUNT(*) AS operations,
+	AVG(total_duration_micro_seconds) AS avg_total_duration_micro_seconds,
+	MAX(total_duration_micro_seconds) AS max_total_duration_micro_seconds,
+	AVG(request_bytes) AS avg_request_bytes,
+	AVG(response_bytes) AS avg_response_bytes,
+	CAST(SUM(request_bytes + response_bytes) AS REAL) / SUM(total_duration_micro_seconds) AS megabytes_per_second
+FROM results
+WHERE outcome = 'ok' AND query_type LIKE 'document_%'
+GROUP BY connection_type, query_type, document_size`
+
+// ThroughputReport is a row of the throughput_report view.
+type ThroughputReport struct {
+	ConnectionType               string
+	QueryType                    string
+	Operations                   int
+	AvgTotalDurationMicroSeconds float64
+	// nil if the driver doesn't measure the client side durations
+	AvgEncodeDurationMicroSeconds *float64
+	AvgDecodeDurationMicroSeconds *float64
+	Bytes                         int
+	Records                       int
+	MegabytesPerSecond            float64
+	// nil if the operations didn't return any records
+	MicroSecondsPerRow *float64
+}
+
+// GraphReport is a row of the graph_report view.
+type GraphReport struct {
+	ConnectionType               string
+	QueryType                    string
+	Depth                        int
+	FanOutBucket                 string
+	MinFanOut                    int
+	Operations                   int
+	AvgTotalDurationMicroSeconds float64
+	MaxTotalDurationMicroSeconds int
+	AvgReachedRecords            float64
+}
+
+// logGraphReport prints the graph_report view.
+func logGraphReport() error {
+	var rows []GraphReport
+	if err := db.Raw(`SELECT * FROM graph_report ORDER BY connection_type, query_type, depth, min_fan_out`).Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		log.Printf("%s %s depth %d fan-out %s: %d operations, avg %.0fµs, max %dµs, avg %.1f reached records \n", row.ConnectionType, row.QueryType, row.Depth, row.FanOutBucket, row.Operations, row.AvgTotalDurationMicroSeconds, row.MaxTotalDurationMicroSeconds, row.AvgReachedRecords)
+	}
+	return nil
+}
+
+// VectorReport is a row of the vector_report view.
+type VectorReport struct {
+	ConnectionType               string
+	QueryType                    string
+	Operations                   int
+	AvgTotalDurationMicroSeconds float64
+	MaxTotalDurationMicroSeconds int
+	AvgRecall                    float64
+	MinRecall                    float64
+}
+
+// logVectorReport prints the vector_report view.
+func logVectorReport() error {
+	var rows []VectorReport
+	if err := db.Raw(`SELECT * FROM vector_report ORDER BY connection_type, query_type`).Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		log.Printf("%s %s: %d operations, avg %.0fµs, max %dµs, avg recall %.3f, min recall %.3f \n", row.ConnectionType, row.QueryType, row.Operations, row.AvgTotalDurationMicroSeconds, row.MaxTotalDurationMicroSeconds, row.AvgRecall, row.MinRecall)
+	}
+	return nil
+}
+
+// PaginationReport is a row of the pagination_report view.
+type PaginationReport struct {
+	ConnectionType               string
+	QueryType                    string
+	PageSize                     int
+	PageOffset                   int
+	Operations                   int
+	AvgTotalDurationMicroSeconds float64
+	MaxTotalDurationMicroSeconds int
+	AvgRecords                   float64
+	// nil if the pages were empty
+	MicroSecondsPerRow *float64
+}
+
+// logPaginationReport prints the pagination_report view.
+func logPaginationReport() error {
+	var rows []PaginationReport
+	if err := db.Raw(`SELECT * FROM pagination_report ORDER BY connection_type, query_type, page_size, page_offset`).Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		log.Printf("%s %s size %d offset %d: %d operations, avg %.0fµs, max %dµs, avg %.1f records, %s per row \n", row.ConnectionType, row.QueryType, row.PageSize, row.PageOffset, row.Operations, row.AvgTotalDurationMicroSeconds, row.MaxTotalDurationMicroSeconds, row.AvgRecords, formatMicroSeconds(row.MicroSecondsPerRow))
+	}
 	return nil
 }
 
-func logResult(connection string, query string, internalDuration int, totalDuration int) {
-	res := Result{
-		ConnectionType:               connection,
-		QueryType:                    query,
-		InternalDurationMicroSeconds: internalDuration,
-		TotalDurationMicroSeconds:    totalDuration,
+// DocumentReport is a row of the document_report view.
+type DocumentReport struct {
+	ConnectionType               string
+	QueryType                    string
+	DocumentSize                 int
+	Operations                   int
+	AvgTotalDurationMicroSeconds float64
+	MaxTotalDurationMicroSeconds int
+	AvgRequestBytes              float64
+	AvgResponseBytes             float64
+	MegabytesPerSecond           float64
+}
+
+// logDocumentReport prints the document_report view with the documents per second of all workers, elapsed is the
+// wall-clock duration of the phase of every connection type.
+func logDocumentReport(elapsed map[string]time.Duration) error {
+	var rows []DocumentReport
+	if err := db.Raw(`SELECT * FROM document_report ORDER BY connection_type, query_type, document_size`).Scan(&rows).Error; err != nil {
+		return err
 	}
+	for _, row := range rows {
+		documentsPerSecond := float64(row.Operations) / elapsed[row.ConnectionType].Seconds()
+		log.Printf("%s %s size %d: %d operations, avg %.0fµs, max %dµs, avg %.0f bytes sent, avg %.0f bytes received, %.2f MB/s, %.1f documents/s \n", row.ConnectionType, row.QueryType, row.DocumentSize, row.Operations, row.AvgTotalDurationMicroSeconds, row.MaxTotalDurationMicroSeconds, row.AvgRequestBytes, row.AvgResponseBytes, row.MegabytesPerSecond, documentsPerSecond)
+	}
+	return nil
+}
+
+// logThroughputReport prints the throughput_report view.
+func logThroughputReport() error {
+	var rows []ThroughputReport
+	if err := db.Raw(`SELECT * FROM throughput_report ORDER BY connection_type, query_type`).Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		log.Printf("%s %s: %d operations, avg %.0fµs (encode %s, decode %s), %.2f MB/s, %d records, %s per row \n", row.ConnectionType, row.QueryType, row.Operations, row.AvgTotalDurationMicroSeconds, formatMicroSeconds(row.AvgEncodeDurationMicroSeconds), formatMicroSeconds(row.AvgDecodeDurationMicroSeconds), row.MegabytesPerSecond, row.Records, formatMicroSeconds(row.MicroSecondsPerRow))
+	}
+	return nil
+}
+
+func newResult(connection string, query string, stats opStats, totalDuration int) Result {
+	requestBytes, responseBytes := stats.sizes()
+	return Result{
+		ConnectionType:                 connection,
+		QueryType:                      query,
+		Outcome:                        outcomeOk,
+		InternalDurationMicroSeconds:   stats.InternalDuration,
+		TotalDurationMicroSeconds:      totalDuration,
+		RequestBytes:                   requestBytes,
+		ResponseBytes:                  responseBytes,
+		Records:                        stats.Records,
+		EncodeDurationMicroSeconds:     stats.EncodeDuration,
+		DecodeDurationMicroSeconds:     stats.DecodeDuration,
+		DnsDurationMicroSeconds:        -1,
+		ConnectDurationMicroSeconds:    -1,
+		TlsDurationMicroSeconds:        -1,
+		FirstByteDurationMicroSeconds:  -1,
+		BodyReadDurationMicroSeconds:   -1,
+		BodyDecodeDurationMicroSeconds: -1,
+		IndexState:                     indexState,
+	}
+}
+
+func (res Result) withTrace(trace *restTrace) Result {
+	res.DnsDurationMicroSeconds = int(trace.Dns.Microseconds())
+	res.ConnectDurationMicroSeconds = int(trace.Connect.Microseconds())
+	res.TlsDurationMicroSeconds = int(trace.Tls.Microseconds())
+	res.FirstByteDurationMicroSeconds = int(trace.FirstByte.Microseconds())
+	res.BodyReadDurationMicroSeconds = int(trace.BodyRead.Microseconds())
+	res.BodyDecodeDurationMicroSeconds = int(trace.BodyDecode.Microseconds())
+	res.ConnectionReused = trace.ConnectionReused
+	return res
+}
+
+func (res Result) withOutcome(outcome string) Result {
+	res.Outcome = outcome
+	return res
+}
+
+func saveResult(res Result) {
 	db.Create(&res)
 }
+
+func logResult(connection string, query string, stats opStats, totalDuration int) {
+	saveResult(newResult(connection, query, stats, totalDuration))
+}
+
+func logRestResult(query string, stats opStats, totalDuration int, trace *restTrace) {
+	saveResult(newResult("REST", query, stats, totalDuration).withTrace(trace))
+}
+
+func logLiveResults(notifications []LiveNotification, summary LiveSubscriberSummary) {
+	if len(notifications) > 0 {
+		db.CreateInBatches(notifications, 1000)
+	}
+	db.Create(&summary)
+}
+
+// countOutcome returns the number of operations logged with outcome.
+func countOutcome(outcome string) (int64, error) {
+	var count int64
+	err := db.Model(&Result{}).Where("outcome = ?", outcome).Count(&count).Error
+	return count, err
+}
+
+// countResults counts the results of a query type on a connection type with outcome.
+func countResults(connection string, query string, outcome string) (int64, error) {
+	var count int64
+	err := db.Model(&Result{}).Where("connection_type = ? AND query_type = ? AND outcome = ?", connection, query, outcome).Count(&count).Error
+	return count, err
+}
+
+// logRunMetadata saves the metadata of the run and returns its id.
+func logRunMetadata(metadata RunMetadata) (int, error) {
+	err := db.Create(&metadata).Error
+	return metadata.ID, err
+}
+
+func logQueryPlan(plan QueryPlan) {
+	db.Create(&plan)
+}
+
+// formatMicroSeconds formats an optional duration of the throughput report, - if it's unknown.
+func formatMicroSeconds(duration *float64) string {
+	if duration == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fµs", *duration)
+}
+
+func logBatchSummary(summary BatchSummary) {
+	db.Create(&summary)
+}
+
+func logTransferSummary(summary TransferSummary) {
+	db.Create(&summary)
+}
+
+func logContentionSummary(summary ContentionSummary) {
+	db.Create(&summary)
+}
+
+func logIndexBuild(build IndexBuild) {
+	db.Create(&build)
+}
+
+func logIndexExperimentSummary(summary IndexExperimentSummary) {
+	db.Create(&summary)
+}
+
+// compareIndexStates returns the average durations of the successful operations of the index experiment with and
+// without indexes per connection and query type, 0 if a query type had no successful operations in a state.
+func compareIndexStates() ([]IndexExperimentSummary, error) {
+	var rows []IndexExperimentSummary
+	err := db.Raw(`SELECT
+	connection_type,
+	query_type,
+	COALESCE(AVG(CASE WHEN index_state = ? THEN total_duration_micro_seconds END), 0) AS avg_without_indexes_micro_seconds,
+	COALESCE(AVG(CASE WHEN index_state = ? THEN total_duration_micro_seconds END), 0) AS avg_with_indexes_micro_seconds
+FROM results
+WHERE outcome = 'ok' AND index_state != ''
+GROUP BY connection_type, query_type
+ORDER BY connection_type, query_type`, withoutIndexes, withIndexes).Scan(&rows).Error
+	return rows, err
+}
+
+func logReconnect(connection string, attempts int, downtime int) {
+	db.Create(&Reconnect{
+		ConnectionType:       connection,
+		Attempts:             attempts,
+		DowntimeMicroSeconds: downtime,
+	})
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,7 +25,7 @@ type SdkQueryResult struct {
 	Result []map[string]interface{} `json:"result"`
 }
 
-func runSdkBenchmark(duration time.Duration, workers int) error {
+func runSdkBenchmark(duration time.Duration, workers int, useMethods bool) error {
 
 	log.Printf("Starting SDK benchmark with %d workers for %d minutes \n", workers, int(duration.Minutes()))
 
@@ -30,7 +33,7 @@ func runSdkBenchmark(duration time.Duration, workers int) error {
 	wg := new(sync.WaitGroup)
 
 	for i := 0; i < workers; i++ {
-		go sdkWorker(wg, ctx)
+		go sdkWorker(wg, ctx, useMethods)
 	}
 
 	for range ctx.Done() {
@@ -45,19 +48,20 @@ func runSdkBenchmark(duration time.Duration, workers int) error {
 }
 
 func prepareSdk() (*surrealdb.DB, error) {
-	db, err := surrealdb.New(wsUrl, surrealdb.UseWriteCompression(true))
+	db, err := surrealdb.New(wsUrl, surrealdb.UseWriteCompression(true), surrealdb.WithTimeout(operationTimeout))
 	if err != nil {
 		return nil, err
 	}
 
 	if _, err = db.Use(db_ns, db_name); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func sdkWorker(wg *sync.WaitGroup, ctx context.Context) error {
+func sdkWorker(wg *sync.WaitGroup, ctx context.Context, useMethods bool) error {
 	db, err := prepareSdk()
 	if err != nil {
 		return err
@@ -74,128 +78,321 @@ func sdkWorker(wg *sync.WaitGroup, ctx context.Context) error {
 			wg.Add(1)
 
 			start := time.Now()
-			id, err := sdkCreate(db)
+			id, stats, err := sdkCreate(ctx, db, useMethods)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "create", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final := time.Since(start)
-			logResult("SDK", "create", -1, int(final.Microseconds()))
+			logResult("SDK", "create", stats, int(final.Microseconds()))
 
 			start = time.Now()
-			err = sdkRead(id, db)
+			stats, err = sdkRead(ctx, id, db, useMethods)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "read", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("SDK", "read", -1, int(final.Microseconds()))
+			logResult("SDK", "read", stats, int(final.Microseconds()))
 
 			start = time.Now()
-			err = sdkUpdate(id, db)
+			stats, err = sdkUpdate(ctx, id, db, useMethods)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "update", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("SDK", "update", -1, int(final.Microseconds()))
+			logResult("SDK", "update", stats, int(final.Microseconds()))
 
 			start = time.Now()
-			err = sdkDelete(id, db)
+			stats, err = sdkDelete(ctx, id, db, useMethods)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "delete", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("SDK", "delete", -1, int(final.Microseconds()))
+			logResult("SDK", "delete", stats, int(final.Microseconds()))
 
 			start = time.Now()
-			dur, err := sdkSelect(db)
+			stats, err = sdkSelect(ctx, db)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "select", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("SDK", "select", dur, int(final.Microseconds()))
+			logResult("SDK", "select", stats, int(final.Microseconds()))
 
 			start = time.Now()
-			dur, err = sdkSimpleQuery(db)
+			stats, err = sdkSimpleQuery(ctx, db)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "query", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("SDK", "query", dur, int(final.Microseconds()))
+			logResult("SDK", "query", stats, int(final.Microseconds()))
 
 			start = time.Now()
-			dur, err = sdkJoinRelation(db)
+			stats, err = sdkJoinRelation(ctx, db)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "join_relation", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("SDK", "join_relation", dur, int(final.Microseconds()))
+			logResult("SDK", "join_relation", stats, int(final.Microseconds()))
 
 			start = time.Now()
-			dur, err = sdkJoinGraph(db)
+			stats, err = sdkJoinGraph(ctx, db)
 			if err != nil {
-				db.Close()
+				db, err = sdkRecover(ctx, db, "join_graph", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("SDK", "join_graph", dur, int(final.Microseconds()))
+			logResult("SDK", "join_graph", stats, int(final.Microseconds()))
 
 			wg.Done()
 		}
 	}
 }
 
-func sdkRead(id string, db *surrealdb.DB) error {
-	data, err := db.Select(id)
+// sdkCall calls an SDK method and returns early with the error of ctx if ctx is done first. The SDK doesn't support
+// contexts, but its own timeout is set to the operation timeout, so the abandoned call returns eventually.
+func sdkCall(ctx context.Context, method func() (interface{}, error)) (interface{}, error) {
+	type response struct {
+		data interface{}
+		err  error
+	}
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+	done := make(chan response, 1)
+	go func() {
+		data, err := method()
+		done <- response{data, err}
+	}()
+	select {
+	case res := <-done:
+		return res.data, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sdkResults converts the response of db.Query into the statement results.
+func sdkResults(data interface{}) ([]map[string]interface{}, error) {
+	raw, ok := data.([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected query response")
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("empty response")
+	}
+	result := make([]map[string]interface{}, len(raw))
+	for i, statement := range raw {
+		result[i], ok = statement.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected query response")
+		}
+	}
+	return result, nil
+}
+
+// sdkMessageStats returns the stats of an SDK call with estimated message sizes. The SDK doesn't expose the raw
+// websocket messages, so the sizes are those of the JSON encoded request and result, which the SDK sends in the same
+// envelope as the Websocket driver. They are encoded when the result is logged, after the call was timed. Records is
+// the number of records in data. The SDK encodes and decodes the messages internally, so only the decoding of data
+// after the SDK returned can be measured.
+func sdkMessageStats(method string, params []interface{}, data interface{}) opStats {
+	stats := opStats{InternalDuration: -1, Records: countRecords(data), EncodeDuration: -1}
+	stats.messageSizes = func() (int, int) {
+		var requestBytes, responseBytes int
+		if request, err := json.Marshal(map[string]interface{}{"id": strings.Repeat("0", 16), "method": method, "params": params}); err == nil {
+			requestBytes = len(request)
+		}
+		if response, err := json.Marshal(map[string]interface{}{"id": strings.Repeat("0", 16), "result": data}); err == nil {
+			responseBytes = len(response)
+		}
+		return requestBytes, responseBytes
+	}
+	return stats
+}
+
+// sdkDecode converts the response of db.Query into the statement results. In typed decode mode the records are
+// decoded into the typed records of query, the same way surrealdb.Unmarshal does it: the SDK already decoded them
+// into generic values, which are encoded to JSON again first. The typed decode duration of the SDK therefore includes
+// that re-encoding and can't be compared with its generic decode duration.
+func sdkDecode(query string, data interface{}) ([]map[string]interface{}, error) {
+	if !typedDecode {
+		return sdkResults(data)
+	}
+	raw, err := json.Marshal(data)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return queryDecoder(query)(raw)
+}
+
+// sdkQuery runs a query and returns the statement results and their stats.
+func sdkQuery(ctx context.Context, db *surrealdb.DB, query string, vars map[string]interface{}) ([]map[string]interface{}, opStats, error) {
+	data, err := sdkCall(ctx, func() (interface{}, error) { return db.Query(query, vars) })
+	if err != nil {
+		return nil, opStats{}, err
+	}
+	decodeStart := time.Now()
+	resp, err := sdkDecode(query, data)
+	if err != nil {
+		return nil, opStats{}, err
 	}
+	decodeDuration := time.Since(decodeStart)
+	if len(resp) == 0 {
+		return nil, opStats{}, errors.New("empty response")
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, opStats{}, err
+	}
+	stats, err := statementStats(resp)
+	if err != nil {
+		return nil, opStats{}, err
+	}
+	messageStats := sdkMessageStats("query", []interface{}{query, vars}, data)
+	stats.messageSizes = messageStats.messageSizes
+	stats.EncodeDuration = messageStats.EncodeDuration
+	stats.DecodeDuration = int(decodeDuration.Microseconds())
+	return resp, stats, nil
+}
+
+// recordKey returns the key of a record id, e.g. abc for customer:abc.
+func recordKey(id string) string {
+	return strings.Split(id, ":")[1]
+}
+
+// sdkRead reads the customer with db.Select if useMethods is set. The SDK methods don't return the internal duration,
+// so otherwise the equivalent query is sent with db.Query to measure it. The same applies to the other CRUD operations.
+func sdkRead(ctx context.Context, id string, db *surrealdb.DB, useMethods bool) (opStats, error) {
+	if seededKeys != nil {
+		return sdkSeededRead(ctx, db, useMethods)
+	}
+	if !useMethods {
+		resp, stats, err := sdkQuery(ctx, db, readQuery, recordVars(recordKey(id)))
+		if err == nil && validateResponses {
+			err = validateCustomer(statementResult(resp), id, createdEmail)
+		}
+		return stats, err
+	}
+	data, err := sdkCall(ctx, func() (interface{}, error) { return db.Select(id) })
+	if err != nil {
+		return opStats{}, err
+	}
+	decodeStart := time.Now()
 	selectedCustomer := new(SdkCustomer)
 	err = surrealdb.Unmarshal(data, &selectedCustomer)
 	if err != nil {
-		panic(err)
+		return opStats{}, err
 	}
-	return nil
+	stats := sdkMessageStats("select", []interface{}{id}, data)
+	stats.DecodeDuration = int(time.Since(decodeStart).Microseconds())
+	if validateResponses {
+		return stats, validateCustomer(data, id, createdEmail)
+	}
+	return stats, nil
 }
 
-func sdkDelete(id string, db *surrealdb.DB) error {
-	_, err := db.Delete(id)
+// sdkDelete deletes the customer. In validation mode the customer is read again afterwards, to check that it's gone.
+func sdkDelete(ctx context.Context, id string, db *surrealdb.DB, useMethods bool) (opStats, error) {
+	if !useMethods {
+		_, stats, err := sdkQuery(ctx, db, deleteQuery, recordVars(recordKey(id)))
+		if err != nil || !validateResponses {
+			return stats, err
+		}
+		resp, _, err := sdkQuery(ctx, db, readQuery, recordVars(recordKey(id)))
+		if err != nil {
+			return stats, err
+		}
+		return stats, validateDeleted(statementResult(resp), id)
+	}
+	data, err := sdkCall(ctx, func() (interface{}, error) { return db.Delete(id) })
 	if err != nil {
-		return err
+		return opStats{}, err
 	}
-	return nil
+	stats := sdkMessageStats("delete", []interface{}{id}, data)
+	if !validateResponses {
+		return stats, nil
+	}
+	data, err = sdkCall(ctx, func() (interface{}, error) { return db.Select(id) })
+	if err != nil {
+		return stats, err
+	}
+	return stats, validateDeleted(data, id)
 }
 
-func sdkUpdate(id string, db *surrealdb.DB) error {
+func sdkUpdate(ctx context.Context, id string, db *surrealdb.DB, useMethods bool) (opStats, error) {
+	if seededKeys != nil {
+		return sdkSeededUpdate(ctx, db, useMethods)
+	}
+	if !useMethods {
+		resp, stats, err := sdkQuery(ctx, db, updateQuery, updateVars(recordKey(id)))
+		if err == nil && validateResponses {
+			err = validateCustomer(statementResult(resp), id, updatedEmail)
+		}
+		return stats, err
+	}
 	changes := map[string]string{"email": "test2@test.com"}
-	if _, err := db.Update(id, changes); err != nil {
-		return err
+	data, err := sdkCall(ctx, func() (interface{}, error) { return db.Update(id, changes) })
+	if err != nil {
+		return opStats{}, err
 	}
-	return nil
+	stats := sdkMessageStats("update", []interface{}{id, changes}, data)
+	if validateResponses {
+		return stats, validateCustomer(data, id, updatedEmail)
+	}
+	return stats, nil
 }
 
-func sdkCreate(db *surrealdb.DB) (string, error) {
+func sdkCreate(ctx context.Context, db *surrealdb.DB, useMethods bool) (string, opStats, error) {
+	if !useMethods {
+		resp, stats, err := sdkQuery(ctx, db, createQuery, createVars())
+		if err != nil {
+			return "", stats, err
+		}
+		if validateResponses {
+			if err := validateCustomer(statementResult(resp), "", createdEmail); err != nil {
+				return "", stats, err
+			}
+		}
+		id := createdId(resp)
+		return id, stats, nil
+	}
+
 	testCustomer := SdkCustomer{
 		FirstName: "Test",
 		LastName:  "Tester",
@@ -204,78 +401,64 @@ func sdkCreate(db *surrealdb.DB) (string, error) {
 		LastLogin: time.Now(),
 	}
 
-	data, err := db.Create("customer", &testCustomer)
+	data, err := sdkCall(ctx, func() (interface{}, error) { return db.Create("customer", &testCustomer) })
 	if err != nil {
-		return "", err
+		return "", opStats{}, err
 	}
+	stats := sdkMessageStats("create", []interface{}{"customer", &testCustomer}, data)
+	if validateResponses {
+		if err := validateCustomer(data, "", createdEmail); err != nil {
+			return "", stats, err
+		}
+	}
+	decodeStart := time.Now()
 	createdCustomer := make([]SdkCustomer, 1)
 	err = surrealdb.Unmarshal(data, &createdCustomer)
 	if err != nil {
-		return "", err
+		return "", opStats{}, err
 	}
+	stats.DecodeDuration = int(time.Since(decodeStart).Microseconds())
 	if len(createdCustomer) == 0 {
-		return "", nil
+		return "", stats, nil
 	}
 
-	return createdCustomer[0].ID, nil
+	return createdCustomer[0].ID, stats, nil
 }
 
-func sdkSelect(db *surrealdb.DB) (int, error) {
-	query := `SELECT * FROM order LIMIT 1000`
-	data, err := db.Query(query, nil)
-	if err != nil {
-		return 0, err
+func sdkSelect(ctx context.Context, db *surrealdb.DB) (opStats, error) {
+	resp, stats, err := sdkQuery(ctx, db, selectQuery, limitVars())
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), selectCheck)
 	}
-	res := data.([]interface{})[0].(map[string]interface{})
-	internalDur, err := time.ParseDuration(res["time"].(string))
-	if err != nil {
-		return 0, err
-	}
-
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func sdkSimpleQuery(db *surrealdb.DB) (int, error) {
-	query := `SELECT * FROM order WHERE processed IS FALSE LIMIT 1000`
-	data, err := db.Query(query, nil)
-	if err != nil {
-		return 0, err
+func sdkSimpleQuery(ctx context.Context, db *surrealdb.DB) (opStats, error) {
+	resp, stats, err := sdkQuery(ctx, db, simpleQuery, processedVars(false))
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), simpleQueryCheck)
 	}
-	res := data.([]interface{})[0].(map[string]interface{})
-	internalDur, err := time.ParseDuration(res["time"].(string))
-	if err != nil {
-		return 0, err
-	}
-
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func sdkJoinRelation(db *surrealdb.DB) (int, error) {
-	query := `SELECT books.title FROM order WHERE processed IS TRUE LIMIT 1000`
-	data, err := db.Query(query, nil)
-	if err != nil {
-		return 0, err
+func sdkJoinRelation(ctx context.Context, db *surrealdb.DB) (opStats, error) {
+	if seededKeys != nil {
+		return sdkSeededJoinRelation(ctx, db)
 	}
-	res := data.([]interface{})[0].(map[string]interface{})
-	internalDur, err := time.ParseDuration(res["time"].(string))
-	if err != nil {
-		return 0, err
+	resp, stats, err := sdkQuery(ctx, db, joinRelationQuery, processedVars(true))
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), joinRelationCheck)
 	}
-
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func sdkJoinGraph(db *surrealdb.DB) (int, error) {
-	query := `SELECT <-ordered<-customer.first_name FROM order WHERE processed IS TRUE LIMIT 1000`
-	data, err := db.Query(query, nil)
-	if err != nil {
-		return 0, err
+func sdkJoinGraph(ctx context.Context, db *surrealdb.DB) (opStats, error) {
+	if seededKeys != nil {
+		return sdkSeededJoinGraph(ctx, db)
 	}
-	res := data.([]interface{})[0].(map[string]interface{})
-	internalDur, err := time.ParseDuration(res["time"].(string))
-	if err != nil {
-		return 0, err
+	resp, stats, err := sdkQuery(ctx, db, joinGraphQuery, processedVars(true))
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), joinGraphCheck)
 	}
-
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	searchAnalyzer          = "benchmark_search"
+	searchTitleIndex        = "benchmark_book_title"
+	searchDescriptionIndex  = "benchmark_book_description"
+	searchLimit             = 100
+	searchVocabularySample  = 1000
+	searchMinimumTermLength = 3
+)
+
+// searchAnalyzerDefinition splits the generated titles and descriptions into lowercase english word stems.
+const searchAnalyzerDefinition = `DEFINE ANALYZER ` + searchAnalyzer + ` TOKENIZERS blank,class,punct FILTERS lowercase,ascii,snowball(english);`
+
+// Full-text search queries on books, logged as query type search_<query>. highlight and score match the description
+// with the reference 1 to highlight the matched terms and order the books by their BM25 score.
+var searchQueries = []struct {
+	name  string
+	query string
+}{
+	{"title", `SELECT id, title FROM book WHERE title @@ $terms LIMIT $limit;`},
+	{"description", `SELECT id, title FROM book WHERE description @@ $terms LIMIT $limit;`},
+	{"highlight", `SELECT id, search::highlight('<b>', '</b>', 1) AS description FROM book WHERE description @1@ $terms LIMIT $limit;`},
+	{"score", `SELECT id, title, search::score(1) AS score FROM book WHERE description @1@ $terms ORDER BY score DESC LIMIT $limit;`},
+}
+
+// runSearchBenchmark defines the analyzer and the search indexes on book titles and descriptions, runs the search
+// queries on every connection type and removes the indexes and the analyzer again.
+func runSearchBenchmark(duration time.Duration, workers int) error {
+	log.Printf("Starting Search benchmark with %d workers for %d minutes per connection type \n", workers, int(duration.Minutes()))
+
+	vocabulary, err := searchVocabulary()
+	if err != nil {
+		return err
+	}
+	log.Printf("Searching %d terms of the book titles and descriptions \n", len(vocabulary))
+
+	if err := searchSetup(); err != nil {
+		searchCleanup()
+		return err
+	}
+
+	run := searchOperation(vocabulary)
+	for _, connection := range []string{"REST", "Websocket", "SDK"} {
+		runPhase("Search "+connection, duration, workers, runQueryWorkers(connection, false, run))
+	}
+
+	if err := searchCleanup(); err != nil {
+		return err
+	}
+
+	log.Println("Search benchmark finished")
+	return nil
+}
+
+// searchVocabulary collects the distinct words of the titles and descriptions of a sample of the books, which
+// prepare_db generated with faker.
+func searchVocabulary() ([]string, error) {
+	resp, _, err := doQuery(context.Background(), `SELECT title, description FROM book LIMIT $limit;`, map[string]interface{}{"limit": searchVocabularySample}, new(restTrace))
+	if err != nil {
+		return nil, err
+	}
+	books, err := recordList(statementResult(resp))
+	if err != nil {
+		return nil, err
+	}
+	words := make(map[string]bool)
+	for _, book := range books {
+		fields, _ := book.(map[string]interface{})
+		for _, field := range []string{"title", "description"} {
+			text, _ := fields[field].(string)
+			for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+				if len(word) >= searchMinimumTermLength {
+					words[strings.ToLower(word)] = true
+				}
+			}
+		}
+	}
+	if len(words) == 0 {
+		return nil, errors.New("the books have no words to search for")
+	}
+	vocabulary := make([]string, 0, len(words))
+	for word := range words {
+		vocabulary = append(vocabulary, word)
+	}
+	return vocabulary, nil
+}
+
+// searchSetup defines the analyzer and builds the search indexes, timing every build.
+func searchSetup() error {
+	if _, err := unboundedQuery(searchAnalyzerDefinition); err != nil {
+		return err
+	}
+	for _, index := range []struct{ name, field string }{{searchTitleIndex, "title"}, {searchDescriptionIndex, "description"}} {
+		definition := `DEFINE INDEX ` + index.name + ` ON book FIELDS ` + index.field + ` SEARCH ANALYZER ` + searchAnalyzer + ` BM25 HIGHLIGHTS;`
+		if err := buildIndex("search", "book", index.name, definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchCleanup removes the search indexes and the analyzer. Indexes that weren't built because the setup failed are
+// skipped.
+func searchCleanup() error {
+	for _, index := range []string{searchTitleIndex, searchDescriptionIndex} {
+		if err := removeIndex("book", index); err != nil && !isQueryError(err) {
+			return err
+		}
+	}
+	_, err := unboundedQuery(`REMOVE ANALYZER ` + searchAnalyzer + `;`)
+	if isQueryError(err) {
+		return nil
+	}
+	return err
+}
+
+// search runs a random search query for one or two random terms of vocabulary.
+func search(exec queryExecutor, vocabulary []string) (string, opStats, error) {
+	searched := searchQueries[rand.Intn(len(searchQueries))]
+	terms := vocabulary[rand.Intn(len(vocabulary))]
+	if rand.Intn(2) == 0 {
+		terms += " " + vocabulary[rand.Intn(len(vocabulary))]
+	}
+	resp, stats, err := exec(searched.query, map[string]interface{}{"terms": terms, "limit": searchLimit})
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), hasFields("id"))
+	}
+	return searched.name, stats, err
+}
+
+// searchOperation returns the operation that runs and logs a random search query for terms of vocabulary.
+func searchOperation(vocabulary []string) queryOperation {
+	return func(w queryWorker) (string, time.Time, error) {
+		start := time.Now()
+		name, stats, err := search(w.exec, vocabulary)
+		if err != nil {
+			return "search_" + name, start, err
+		}
+		saveResult(w.result("search_"+name, stats, start))
+		return "", start, nil
+	}
+}
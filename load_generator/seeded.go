@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/surrealdb/surrealdb.go"
+)
+
+// seededKeys picks the seeded records that the read, update and join operations of the REST, Websocket and SDK
+// phases access. If it's nil, read and update access the customer the worker just created and the joins select the
+// first orders by processed.
+var seededKeys *seededAccess
+
+// seededAccess picks customers from customer:0 to customer:199999 and orders from order:0 to order:599999.
+type seededAccess struct {
+	customers keyChooser
+	orders    keyChooser
+}
+
+func newSeededAccess(distribution string, skew float64) (*seededAccess, error) {
+	// the seeded records are never deleted, so the latest records are always the ones with the highest ids
+	customers, err := newKeyChooser(distribution, skew, customerCount, func() int64 { return customerCount })
+	if err != nil {
+		return nil, err
+	}
+	orders, err := newKeyChooser(distribution, skew, orderCount, func() int64 { return orderCount })
+	if err != nil {
+		return nil, err
+	}
+	return &seededAccess{customers: customers, orders: orders}, nil
+}
+
+// Query templates of the operations on seeded records. The update only sets benchmark_touched, which is removed again
+// by seededCleanup, so the seeded data isn't changed. The joins select the same fields as joinRelationQuery and
+// joinGraphQuery from a single order.
+const (
+	seededUpdateQuery       = `UPDATE type::thing($tb, $id) SET benchmark_touched = $touched;`
+	seededJoinRelationQuery = `SELECT books.title FROM type::thing($tb, $id);`
+	seededJoinGraphQuery    = `SELECT <-ordered<-customer.first_name FROM type::thing($tb, $id);`
+)
+
+// seededVars binds a seeded record. The seeded records have numeric ids, so the key must not be sent as string.
+func seededVars(table string, key int64) map[string]interface{} {
+	return map[string]interface{}{"tb": table, "id": key}
+}
+
+func seededUpdateVars(key int64) map[string]interface{} {
+	vars := seededVars("customer", key)
+	vars["touched"] = time.Now().UnixNano()
+	return vars
+}
+
+func seededId(table string, key int64) string {
+	return table + ":" + strconv.FormatInt(key, 10)
+}
+
+// seededCleanup removes benchmark_touched from the updated customers.
+func seededCleanup() error {
+	_, _, err := doQuery(context.Background(), `UPDATE customer SET benchmark_touched = NONE WHERE benchmark_touched != NONE;`, nil, new(restTrace))
+	return err
+}
+
+// validateSeededOrder checks the result of a join on a seeded order.
+func validateSeededOrder(result interface{}, check func(map[string]interface{}) error) error {
+	records, err := recordList(result)
+	if err != nil {
+		return err
+	}
+	if len(records) != 1 {
+		return invalid("expected 1 order, got %d", len(records))
+	}
+	return validateRows(records, check)
+}
+
+func restSeededRead(ctx context.Context, trace *restTrace) (opStats, error) {
+	key := seededKeys.customers.next()
+	resp, stats, err := doRequest(ctx, "GET", "/key/customer/"+strconv.FormatInt(key, 10), nil, customerDecoder(), trace)
+	if err == nil && validateResponses {
+		err = validateRecord(statementResult(resp), seededId("customer", key))
+	}
+	return stats, err
+}
+
+func restSeededUpdate(ctx context.Context, trace *restTrace) (opStats, error) {
+	key := seededKeys.customers.next()
+	body := strings.NewReader(`{"benchmark_touched":` + strconv.FormatInt(time.Now().UnixNano(), 10) + `}`)
+	resp, stats, err := doRequest(ctx, "PATCH", "/key/customer/"+strconv.FormatInt(key, 10), body, customerDecoder(), trace)
+	if err == nil && validateResponses {
+		err = validateRecord(statementResult(resp), seededId("customer", key))
+	}
+	return stats, err
+}
+
+func restSeededJoinRelation(ctx context.Context, trace *restTrace) (opStats, error) {
+	resp, stats, err := doQuery(ctx, seededJoinRelationQuery, seededVars("order", seededKeys.orders.next()), trace)
+	if err == nil && validateResponses {
+		err = validateSeededOrder(statementResult(resp), joinRelationCheck)
+	}
+	return stats, err
+}
+
+func restSeededJoinGraph(ctx context.Context, trace *restTrace) (opStats, error) {
+	resp, stats, err := doQuery(ctx, seededJoinGraphQuery, seededVars("order", seededKeys.orders.next()), trace)
+	if err == nil && validateResponses {
+		err = validateSeededOrder(statementResult(resp), joinGraphCheck)
+	}
+	return stats, err
+}
+
+func websocketSeededRead(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	key := seededKeys.customers.next()
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, readQuery, seededVars("customer", key))
+	if err == nil && validateResponses {
+		err = validateRecord(statementResult(resp), seededId("customer", key))
+	}
+	return stats, err
+}
+
+func websocketSeededUpdate(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	key := seededKeys.customers.next()
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, seededUpdateQuery, seededUpdateVars(key))
+	if err == nil && validateResponses {
+		err = validateRecord(statementResult(resp), seededId("customer", key))
+	}
+	return stats, err
+}
+
+func websocketSeededJoinRelation(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, seededJoinRelationQuery, seededVars("order", seededKeys.orders.next()))
+	if err == nil && validateResponses {
+		err = validateSeededOrder(statementResult(resp), joinRelationCheck)
+	}
+	return stats, err
+}
+
+func websocketSeededJoinGraph(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, seededJoinGraphQuery, seededVars("order", seededKeys.orders.next()))
+	if err == nil && validateResponses {
+		err = validateSeededOrder(statementResult(resp), joinGraphCheck)
+	}
+	return stats, err
+}
+
+// sdkSeededRead reads a seeded customer with db.Select if useMethods is set, like sdkRead.
+func sdkSeededRead(ctx context.Context, db *surrealdb.DB, useMethods bool) (opStats, error) {
+	key := seededKeys.customers.next()
+	id := seededId("customer", key)
+	if !useMethods {
+		resp, stats, err := sdkQuery(ctx, db, readQuery, seededVars("customer", key))
+		if err == nil && validateResponses {
+			err = validateRecord(statementResult(resp), id)
+		}
+		return stats, err
+	}
+	data, err := sdkCall(ctx, func() (interface{}, error) { return db.Select(id) })
+	if err != nil {
+		return opStats{}, err
+	}
+	stats := sdkMessageStats("select", []interface{}{id}, data)
+	if validateResponses {
+		return stats, validateRecord(data, id)
+	}
+	return stats, nil
+}
+
+// sdkSeededUpdate updates a seeded customer with db.Change if useMethods is set. db.Update would replace the record.
+func sdkSeededUpdate(ctx context.Context, db *surrealdb.DB, useMethods bool) (opStats, error) {
+	key := seededKeys.customers.next()
+	id := seededId("customer", key)
+	if !useMethods {
+		resp, stats, err := sdkQuery(ctx, db, seededUpdateQuery, seededUpdateVars(key))
+		if err == nil && validateResponses {
+			err = validateRecord(statementResult(resp), id)
+		}
+		return stats, err
+	}
+	changes := map[string]interface{}{"benchmark_touched": time.Now().UnixNano()}
+	data, err := sdkCall(ctx, func() (interface{}, error) { return db.Change(id, changes) })
+	if err != nil {
+		return opStats{}, err
+	}
+	stats := sdkMessageStats("merge", []interface{}{id, changes}, data)
+	if validateResponses {
+		return stats, validateRecord(data, id)
+	}
+	return stats, nil
+}
+
+func sdkSeededJoinRelation(ctx context.Context, db *surrealdb.DB) (opStats, error) {
+	resp, stats, err := sdkQuery(ctx, db, seededJoinRelationQuery, seededVars("order", seededKeys.orders.next()))
+	if err == nil && validateResponses {
+		err = validateSeededOrder(statementResult(resp), joinRelationCheck)
+	}
+	return stats, err
+}
+
+func sdkSeededJoinGraph(ctx context.Context, db *surrealdb.DB) (opStats, error) {
+	resp, stats, err := sdkQuery(ctx, db, seededJoinGraphQuery, seededVars("order", seededKeys.orders.next()))
+	if err == nil && validateResponses {
+		err = validateSeededOrder(statementResult(resp), joinGraphCheck)
+	}
+	return stats, err
+}
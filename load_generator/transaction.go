@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+type transactionConfig struct {
+	// books is the number of books (book:0 to book:books-1) the transactions update, fewer books mean more conflicts
+	books   int
+	retries int
+}
+
+func runTransactionBenchmark(duration time.Duration, workers int, cfg transactionConfig) error {
+	log.Printf("Starting Transaction benchmark with %d workers for %d minutes per connection type on %d books \n", workers, int(duration.Minutes()), cfg.books)
+
+	run := transactionOperation(cfg)
+	for _, connection := range []string{"REST", "Websocket", "SDK"} {
+		runPhase("Transaction "+connection, duration, workers, runQueryWorkers(connection, false, run))
+	}
+
+	if err := transactionCleanup(); err != nil {
+		return err
+	}
+
+	log.Println("Transaction benchmark finished")
+	return nil
+}
+
+// runTransaction executes a transaction until it commits or it conflicted more than retries times, and reports whether
+// it committed. Every attempt is logged: conflicting attempts as conflict, the successful attempt as commit,
+// or as retry if it only succeeded after at least one conflict. Any other error ends the transaction and is returned,
+// so the worker logs the failed attempt with its recover function.
+func runTransaction(retries int, attempt func() (Result, error)) (bool, error) {
+	return runAttempts(retries, outcomeCommit, attempt)
+}
+
+// runAttempts is runTransaction with the outcome of an attempt that succeeded without conflicts.
+func runAttempts(retries int, outcome string, attempt func() (Result, error)) (bool, error) {
+	for i := 0; ; i++ {
+		res, err := attempt()
+		switch {
+		case isConflict(err):
+			res.InternalDurationMicroSeconds = -1
+			saveResult(res.withOutcome(outcomeConflict))
+			if i >= retries {
+				return false, nil
+			}
+		case err != nil:
+			return false, err
+		case i == 0:
+			saveResult(res.withOutcome(outcome))
+			return true, nil
+		default:
+			saveResult(res.withOutcome(outcomeRetry))
+			return true, nil
+		}
+	}
+}
+
+// transactionVars picks a random order id, customer and book for transactionQuery.
+func transactionVars(cfg transactionConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"order":    fmt.Sprintf("tx%d", rand.Int63()),
+		"book":     rand.Intn(cfg.books),
+		"customer": rand.Intn(customerCount),
+	}
+}
+
+// transactionOperation returns the operation that runs transactionQuery on random records until it commits.
+func transactionOperation(cfg transactionConfig) queryOperation {
+	return func(w queryWorker) (string, time.Time, error) {
+		vars := transactionVars(cfg)
+		var start time.Time
+		_, err := runTransaction(cfg.retries, func() (Result, error) {
+			start = time.Now()
+			_, stats, err := w.exec(transactionQuery, vars)
+			return w.result("transaction", stats, start), err
+		})
+		return "transaction", start, err
+	}
+}
+
+// transactionCleanup removes the orders and the stock written during the benchmark.
+// Deleting the orders also deletes their ordered edges.
+func transactionCleanup() error {
+	ws, err := prepareWebsocket()
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	_, _, err = wsSendMessage(context.Background(), ws, 2, `DELETE order WHERE benchmark = true; UPDATE book SET stock = NONE WHERE stock != NONE;`, nil)
+	return err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Emails of the test customer after the create and after the update operation.
+const (
+	createdEmail = "test@test.com"
+	updatedEmail = "test2@test.com"
+)
+
+// validationError is returned when a response has status OK, but doesn't contain what the operation should return.
+type validationError struct {
+	Detail string
+}
+
+func (e *validationError) Error() string {
+	return "validation failed: " + e.Detail
+}
+
+// isInvalid reports whether err is caused by a response that failed validation.
+func isInvalid(err error) bool {
+	var vErr *validationError
+	return errors.As(err, &vErr)
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &validationError{Detail: fmt.Sprintf(format, args...)}
+}
+
+// recordList returns the records of a statement or RPC result, which is either an array of records, a single record
+// or empty.
+func recordList(result interface{}) ([]interface{}, error) {
+	switch records := result.(type) {
+	case []interface{}:
+		return records, nil
+	case map[string]interface{}:
+		return []interface{}{records}, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, invalid("unexpected result %T", result)
+	}
+}
+
+// validateCustomer checks that result is exactly the test customer with the given email. id is the full record id,
+// e.g. customer:abc, and isn't checked if empty.
+func validateCustomer(result interface{}, id string, email string) error {
+	records, err := recordList(result)
+	if err != nil {
+		return err
+	}
+	if len(records) != 1 {
+		return invalid("expected 1 customer, got %d", len(records))
+	}
+	customer, ok := records[0].(map[string]interface{})
+	if !ok {
+		return invalid("unexpected record %T", records[0])
+	}
+	if id != "" && customer["id"] != id {
+		return invalid("expected customer %s, got %v", id, customer["id"])
+	}
+	if customer["first_name"] != "Test" || customer["last_name"] != "Tester" {
+		return invalid("customer %v is not the test customer", customer["id"])
+	}
+	if customer["email"] != email {
+		return invalid("expected email %s of customer %v, got %v", email, customer["id"], customer["email"])
+	}
+	return nil
+}
+
+// validateRecord checks that result is exactly the record with the full id, e.g. customer:0.
+func validateRecord(result interface{}, id string) error {
+	records, err := recordList(result)
+	if err != nil {
+		return err
+	}
+	if len(records) != 1 {
+		return invalid("expected record %s, got %d records", id, len(records))
+	}
+	record, ok := records[0].(map[string]interface{})
+	if !ok {
+		return invalid("unexpected record %T", records[0])
+	}
+	if record["id"] != id {
+		return invalid("expected record %s, got %v", id, record["id"])
+	}
+	return nil
+}
+
+// validateDeleted checks that result, the result of reading a deleted record, is empty.
+func validateDeleted(result interface{}, id string) error {
+	records, err := recordList(result)
+	if err != nil {
+		return err
+	}
+	if len(records) != 0 {
+		return invalid("customer %s still exists after delete", id)
+	}
+	return nil
+}
+
+// validateRows checks that result contains at most queryLimit records and that check passes for every record.
+func validateRows(result interface{}, check func(map[string]interface{}) error) error {
+	records, err := recordList(result)
+	if err != nil {
+		return err
+	}
+	if len(records) > queryLimit {
+		return invalid("expected at most %d records, got %d", queryLimit, len(records))
+	}
+	for _, record := range records {
+		fields, ok := record.(map[string]interface{})
+		if !ok {
+			return invalid("unexpected record %T", record)
+		}
+		if err := check(fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasFields returns a check for validateRows that requires the given fields.
+func hasFields(names ...string) func(map[string]interface{}) error {
+	return func(fields map[string]interface{}) error {
+		for _, name := range names {
+			if _, ok := fields[name]; !ok {
+				return invalid("record %v without field %s", fields["id"], name)
+			}
+		}
+		return nil
+	}
+}
+
+// isProcessed returns a check for validateRows that requires processed to be the bound value.
+func isProcessed(processed bool) func(map[string]interface{}) error {
+	return func(fields map[string]interface{}) error {
+		if fields["processed"] != processed {
+			return invalid("order %v has processed = %v, expected %v", fields["id"], fields["processed"], processed)
+		}
+		return nil
+	}
+}
+
+// Checks of the select, query and join operations.
+var (
+	selectCheck       = hasFields("id", "processed", "books")
+	simpleQueryCheck  = isProcessed(false)
+	joinRelationCheck = hasFields("books")
+	joinGraphCheck    = hasFields("<-ordered")
+)
+
+// statementResult returns the result of the first statement of a query.
+func statementResult(resp []map[string]interface{}) interface{} {
+	return resp[0]["result"]
+}
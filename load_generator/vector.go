@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vector indexes on the book embeddings, logged as query type vector_<index>. Only one of them exists at a time, so
+// the nearest neighbour queries always use the index of the phase.
+var vectorIndexDefinitions = map[string]string{
+	"mtree": `DEFINE INDEX benchmark_book_mtree ON book FIELDS embedding MTREE DIMENSION %d DIST EUCLIDEAN;`,
+	"hnsw":  `DEFINE INDEX benchmark_book_hnsw ON book FIELDS embedding HNSW DIMENSION %d DIST EUCLIDEAN;`,
+}
+
+type vectorConfig struct {
+	// dimension has to be the dimension the dataset was generated with
+	dimension int
+	k         int
+	// queries is the number of query vectors, for which the nearest neighbours are computed before the phases
+	queries int
+	indexes []string
+}
+
+// parseVectorIndexes parses a comma separated list of vector indexes, e.g. "mtree,hnsw".
+func parseVectorIndexes(list string) ([]string, error) {
+	var indexes []string
+	for _, field := range strings.Split(list, ",") {
+		index := strings.ToLower(strings.TrimSpace(field))
+		if _, ok := vectorIndexDefinitions[index]; !ok {
+			return nil, fmt.Errorf("unknown vector index %q", field)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// vectorCase is a query vector and the ids of its k nearest books.
+type vectorCase struct {
+	vector  []float64
+	nearest map[string]bool
+}
+
+// vectorPhase runs the nearest neighbour queries on an index.
+type vectorPhase struct {
+	queryType string
+	query     string
+	k         int
+	cases     []vectorCase
+}
+
+// runVectorBenchmark computes the nearest books of random query vectors, then builds every index, runs the nearest
+// neighbour queries on it on every connection type and removes it again.
+func runVectorBenchmark(duration time.Duration, workers int, cfg vectorConfig) error {
+	log.Printf("Starting Vector benchmark with %d workers for %d minutes per index and connection type \n", workers, int(duration.Minutes()))
+
+	if err := checkEmbeddings(cfg.dimension); err != nil {
+		return err
+	}
+	start := time.Now()
+	cases := vectorGroundTruth(cfg)
+	log.Printf("Computed the %d nearest books of %d query vectors in %v \n", cfg.k, cfg.queries, time.Since(start))
+
+	for _, index := range cfg.indexes {
+		name := "benchmark_book_" + index
+		if err := buildIndex("vector", "book", name, fmt.Sprintf(vectorIndexDefinitions[index], cfg.dimension)); err != nil {
+			removeIndex("book", name)
+			return err
+		}
+		phase := &vectorPhase{
+			queryType: "vector_" + index,
+			query:     `SELECT id, vector::distance::euclidean(embedding, $vector) AS distance FROM book WHERE embedding <|` + strconv.Itoa(cfg.k) + `|> $vector;`,
+			k:         cfg.k,
+			cases:     cases,
+		}
+
+		for _, connection := range []string{"REST", "Websocket", "SDK"} {
+			runPhase("Vector "+index+" "+connection, duration, workers, runQueryWorkers(connection, false, phase.run))
+		}
+
+		if err := removeIndex("book", name); err != nil {
+			return err
+		}
+	}
+
+	if err := logVectorReport(); err != nil {
+		return err
+	}
+
+	log.Println("Vector benchmark finished")
+	return nil
+}
+
+// mulberry32 is the random generator prepare_db generates the embeddings with.
+type mulberry32 uint32
+
+func (m *mulberry32) next() float64 {
+	*m += 0x6d2b79f5
+	seed := uint32(*m)
+	t := (seed ^ seed>>15) * (1 | seed)
+	t = (t + (t^t>>7)*(61|t)) ^ t
+	return float64(t^t>>14) / 4294967296
+}
+
+// bookEmbedding computes the embedding of a book like prepare_db into embedding. Math.round of JavaScript rounds
+// halves up.
+func bookEmbedding(id int, embedding []float64) {
+	random := mulberry32(id)
+	for i := range embedding {
+		scaled := (random.next()*2 - 1) * 10000
+		rounded := math.Floor(scaled)
+		if scaled-rounded >= 0.5 {
+			rounded++
+		}
+		embedding[i] = rounded / 10000
+	}
+}
+
+// checkEmbeddings fails if the embedding of book:0 isn't the one prepare_db generates with dimension.
+func checkEmbeddings(dimension int) error {
+	resp, _, err := doQuery(context.Background(), `SELECT VALUE embedding FROM book:0;`, nil, new(restTrace))
+	if err != nil {
+		return err
+	}
+	stored, _ := statementResult(resp).([]interface{})
+	var components []interface{}
+	if len(stored) == 1 {
+		components, _ = stored[0].([]interface{})
+	}
+	expected := make([]float64, dimension)
+	bookEmbedding(0, expected)
+	mismatch := len(components) != dimension
+	for i := 0; !mismatch && i < dimension; i++ {
+		component, _ := components[i].(float64)
+		mismatch = component != expected[i]
+	}
+	if mismatch {
+		return fmt.Errorf("the books have no embeddings of dimension %d, generate the dataset with prepare_db --embedding-dimension %d", dimension, dimension)
+	}
+	return nil
+}
+
+// vectorGroundTruth generates cfg.queries random query vectors and finds their cfg.k nearest books by comparing them
+// with the embedding of every book.
+func vectorGroundTruth(cfg vectorConfig) []vectorCase {
+	vectors := make([][]float64, cfg.queries)
+	nearest := make([]*neighbours, cfg.queries)
+	for i := range vectors {
+		vectors[i] = make([]float64, cfg.dimension)
+		for j := range vectors[i] {
+			vectors[i][j] = rand.Float64()*2 - 1
+		}
+		nearest[i] = &neighbours{k: cfg.k}
+	}
+
+	embedding := make([]float64, cfg.dimension)
+	for id := 0; id < bookCount; id++ {
+		bookEmbedding(id, embedding)
+		for i, vector := range vectors {
+			distance := 0.0
+			for j, component := range vector {
+				d := component - embedding[j]
+				distance += d * d
+			}
+			nearest[i].add(id, distance)
+		}
+	}
+
+	cases := make([]vectorCase, cfg.queries)
+	for i := range cases {
+		cases[i] = vectorCase{vector: vectors[i], nearest: make(map[string]bool, cfg.k)}
+		for _, id := range nearest[i].ids {
+			cases[i].nearest[seededId("book", int64(id))] = true
+		}
+	}
+	return cases
+}
+
+// neighbours keeps the k nearest ids sorted by their squared distance.
+type neighbours struct {
+	k         int
+	ids       []int
+	distances []float64
+}
+
+func (n *neighbours) add(id int, distance float64) {
+	if len(n.ids) == n.k && distance >= n.distances[n.k-1] {
+		return
+	}
+	i := len(n.ids)
+	if i == n.k {
+		i--
+	} else {
+		n.ids = append(n.ids, 0)
+		n.distances = append(n.distances, 0)
+	}
+	for ; i > 0 && n.distances[i-1] > distance; i-- {
+		n.ids[i] = n.ids[i-1]
+		n.distances[i] = n.distances[i-1]
+	}
+	n.ids[i] = id
+	n.distances[i] = distance
+}
+
+// search runs the nearest neighbour query of a random query vector and returns the recall, the fraction of the true
+// nearest books that the query returned.
+func (p *vectorPhase) search(exec queryExecutor) (opStats, float64, error) {
+	vectorCase := p.cases[rand.Intn(len(p.cases))]
+	resp, stats, err := exec(p.query, map[string]interface{}{"vector": vectorCase.vector})
+	if err != nil {
+		return stats, 0, err
+	}
+	books, err := recordList(statementResult(resp))
+	if err == nil && len(books) > p.k {
+		err = invalid("expected at most %d books, got %d", p.k, len(books))
+	}
+	if err == nil && validateResponses {
+		err = validateRows(books, hasFields("id", "distance"))
+	}
+	if err != nil {
+		return stats, 0, err
+	}
+	found := 0
+	for _, book := range books {
+		fields, _ := book.(map[string]interface{})
+		if id, _ := fields["id"].(string); vectorCase.nearest[id] {
+			found++
+		}
+	}
+	return stats, float64(found) / float64(len(vectorCase.nearest)), nil
+}
+
+// run runs the nearest neighbour query of a random query vector and logs it with its recall.
+func (p *vectorPhase) run(w queryWorker) (string, time.Time, error) {
+	start := time.Now()
+	stats, recall, err := p.search(w.exec)
+	if err != nil {
+		return p.queryType, start, err
+	}
+	res := w.result(p.queryType, stats, start)
+	res.Recall = &recall
+	saveResult(res)
+	return "", start, nil
+}
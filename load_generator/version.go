@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// dialect describes the parts of the HTTP protocol that differ between SurrealDB releases. Only the names of the
+// namespace and database headers are switched, all drivers expect the /key endpoints and the statement results of 1.x.
+type dialect struct {
+	Name            string
+	NamespaceHeader string
+	DatabaseHeader  string
+}
+
+var (
+	// dialectV1 is the protocol of SurrealDB 1.x
+	dialectV1 = dialect{Name: "v1", NamespaceHeader: "NS", DatabaseHeader: "DB"}
+	// dialectV2 is the protocol of SurrealDB 2.x, which renamed the namespace and database headers
+	dialectV2 = dialect{Name: "v2", NamespaceHeader: "Surreal-NS", DatabaseHeader: "Surreal-DB"}
+)
+
+// serverDialect is the dialect of the benchmarked server, set at startup
+var serverDialect = dialectV1
+
+// setHeaders sets the namespace and database headers of req.
+func (d dialect) setHeaders(req *http.Request) {
+	req.Header.Set(d.NamespaceHeader, db_ns)
+	req.Header.Set(d.DatabaseHeader, db_name)
+}
+
+// fetchVersion returns the version reported by /version, e.g. surrealdb-1.1.1.
+func fetchVersion() (string, error) {
+	resp, err := http.Get(url + "/version")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("version request failed: %v", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// dialectForVersion picks the dialect of a version reported by /version. Releases newer than the newest known
+// dialect are benchmarked with it.
+func dialectForVersion(version string) (dialect, error) {
+	number := strings.TrimPrefix(version, "surrealdb-")
+	major, err := strconv.Atoi(strings.SplitN(number, ".", 2)[0])
+	if err != nil {
+		return dialect{}, fmt.Errorf("unknown version %q", version)
+	}
+	switch {
+	case major <= 1:
+		return dialectV1, nil
+	case major == 2:
+		return dialectV2, nil
+	default:
+		log.Printf("SurrealDB %s is newer than the supported releases, using dialect %s \n", number, dialectV2.Name)
+		return dialectV2, nil
+	}
+}
+
+// dialectByName returns the dialect forced with -dialect.
+func dialectByName(name string) (dialect, error) {
+	for _, d := range []dialect{dialectV1, dialectV2} {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+	return dialect{}, fmt.Errorf("unknown dialect %q", name)
+}
@@ -2,24 +2,32 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
 	"time"
-
-	"golang.org/x/net/websocket"
 )
 
 type WebsocketSend struct {
-	Id     int      `json:"id"`
-	Method string   `json:"method"`
-	Params []string `json:"params"`
+	Id     int           `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
 }
 
+// WebsocketReceive is a response to an RPC call. Result is decoded separately, so that the decoder can depend on
+// the call.
 type WebsocketReceive struct {
-	Id     int                      `json:"id"`
-	Result []map[string]interface{} `json:"result"`
+	Id     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *WebsocketError `json:"error"`
+}
+
+type WebsocketError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
 }
 
 func runWebsocketBenchmark(duration time.Duration, workers int) error {
@@ -43,22 +51,32 @@ func runWebsocketBenchmark(duration time.Duration, workers int) error {
 	return nil
 }
 
-func prepareWebsocket() (*websocket.Conn, error) {
-
-	ws, err := websocket.Dial(wsUrl, "", url)
+func prepareWebsocket() (wsConn, error) {
+	ws, err := dialWebsocket()
 	if err != nil {
 		return nil, err
 	}
-	ws.MaxPayloadBytes = 1024 * 1024 * 1024
-	if _, err := ws.Write([]byte(`{"id":1,"method":"use","params":["` + db_ns + `", "` + db_name + `"]}`)); err != nil {
+	if err := ws.SetDeadline(time.Now().Add(operationTimeout)); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	if err := ws.Send([]byte(`{"id":1,"method":"use","params":["` + db_ns + `", "` + db_name + `"]}`)); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	response, err := ws.Receive()
+	if err != nil {
+		ws.Close()
 		return nil, err
 	}
 	var msg WebsocketReceive
-	if err = websocket.JSON.Receive(ws, &msg); err != nil {
+	if err = json.Unmarshal(response, &msg); err != nil {
+		ws.Close()
 		return nil, err
 	}
 
 	if msg.Id != 1 {
+		ws.Close()
 		return nil, errors.New("unexpected websocket response id")
 	}
 
@@ -83,99 +101,115 @@ func websocketWorker(wg *sync.WaitGroup, ctx context.Context) error {
 			wg.Add(1)
 
 			start := time.Now()
-			id, dur, err := websocketCreate(ws, nextId)
+			id, stats, err := websocketCreate(ctx, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "create", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final := time.Since(start)
-			logResult("Websocket", "create", dur, int(final.Microseconds()))
+			logResult("Websocket", "create", stats, int(final.Microseconds()))
 			nextId++
 
 			start = time.Now()
-			dur, err = websocketRead(id, ws, nextId)
+			stats, err = websocketRead(ctx, id, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "read", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("Websocket", "read", dur, int(final.Microseconds()))
+			logResult("Websocket", "read", stats, int(final.Microseconds()))
 			nextId++
 
 			start = time.Now()
-			dur, err = websocketUpdate(id, ws, nextId)
+			stats, err = websocketUpdate(ctx, id, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "update", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("Websocket", "update", dur, int(final.Microseconds()))
+			logResult("Websocket", "update", stats, int(final.Microseconds()))
 			nextId++
 
 			start = time.Now()
-			dur, err = websocketDelete(id, ws, nextId)
+			stats, err = websocketDelete(ctx, id, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "delete", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("Websocket", "delete", dur, int(final.Microseconds()))
-			nextId++
+			logResult("Websocket", "delete", stats, int(final.Microseconds()))
+			nextId += 2
 
 			start = time.Now()
-			dur, err = websocketSelect(ws, nextId)
+			stats, err = websocketSelect(ctx, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "select", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("Websocket", "select", dur, int(final.Microseconds()))
+			logResult("Websocket", "select", stats, int(final.Microseconds()))
 			nextId++
 
 			start = time.Now()
-			dur, err = websocketSimpleQuery(ws, nextId)
+			stats, err = websocketSimpleQuery(ctx, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "query", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("Websocket", "query", dur, int(final.Microseconds()))
+			logResult("Websocket", "query", stats, int(final.Microseconds()))
 			nextId++
 
 			start = time.Now()
-			dur, err = websocketJoinRelation(ws, nextId)
+			stats, err = websocketJoinRelation(ctx, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "join_relation", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("Websocket", "join_relation", dur, int(final.Microseconds()))
+			logResult("Websocket", "join_relation", stats, int(final.Microseconds()))
 			nextId++
 
 			start = time.Now()
-			dur, err = websocketJoinGraph(ws, nextId)
+			stats, err = websocketJoinGraph(ctx, ws, nextId)
 			if err != nil {
-				ws.Close()
+				ws, err = websocketRecover(ctx, ws, "join_graph", start, err)
 				wg.Done()
-				log.Println(err)
-				return err
+				if err != nil {
+					return err
+				}
+				continue
 			}
 			final = time.Since(start)
-			logResult("Websocket", "join_graph", dur, int(final.Microseconds()))
+			logResult("Websocket", "join_graph", stats, int(final.Microseconds()))
 			nextId++
 
 			wg.Done()
@@ -183,141 +217,197 @@ func websocketWorker(wg *sync.WaitGroup, ctx context.Context) error {
 	}
 }
 
-func wsSendMessage(ws *websocket.Conn, id int, query string) ([]map[string]interface{}, error) {
-	sMsg := WebsocketSend{
-		Id:     id,
-		Method: "query",
-		Params: []string{query},
+// wsSendMessage sends a query and binds vars as its variables, vars may be nil.
+func wsSendMessage(ctx context.Context, ws wsConn, id int, query string, vars map[string]interface{}) ([]map[string]interface{}, opStats, error) {
+	params := []interface{}{query}
+	if vars != nil {
+		params = append(params, vars)
 	}
-	if err := websocket.JSON.Send(ws, sMsg); err != nil {
-		return nil, err
+	result, rpcStats, err := wsCall(ctx, ws, id, queryDecoder(query), "query", params)
+	if err != nil {
+		return nil, opStats{}, err
 	}
+	if len(result) == 0 {
+		return nil, opStats{}, errors.New("empty response")
+	}
+	if err := checkStatus(result); err != nil {
+		return nil, opStats{}, err
+	}
+	stats, err := statementStats(result)
+	if err != nil {
+		return nil, opStats{}, err
+	}
+	stats.RequestBytes = rpcStats.RequestBytes
+	stats.ResponseBytes = rpcStats.ResponseBytes
+	stats.EncodeDuration = rpcStats.EncodeDuration
+	stats.DecodeDuration = rpcStats.DecodeDuration
+	return result, stats, nil
+}
 
-	// bytes := make([]byte, 1024)
-	// _, err := ws.Read(bytes)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// fmt.Printf("Received: %s\n", string(bytes))
+// wsSendRpc calls an RPC method and returns its result without interpreting it. The call fails after the operation
+// timeout or when ctx is done, afterwards the connection can't be used anymore. The RPC methods don't report the
+// internal duration, so the returned stats only contain the message sizes, the client side durations and the number
+// of results.
+func wsSendRpc(ctx context.Context, ws wsConn, id int, method string, params ...interface{}) ([]map[string]interface{}, opStats, error) {
+	return wsCall(ctx, ws, id, decodeGeneric, method, params)
+}
 
-	var msg WebsocketReceive
-	if err := websocket.JSON.Receive(ws, &msg); err != nil {
-		return nil, err
+// wsCall is wsSendRpc with the decoder of the result. Decoding the response envelope and the result is measured as
+// decode duration.
+func wsCall(ctx context.Context, ws wsConn, id int, decode decoder, method string, params []interface{}) ([]map[string]interface{}, opStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	if err := ws.SetDeadline(deadline); err != nil {
+		return nil, opStats{}, err
 	}
-	if msg.Id != id {
-		return nil, errors.New("unexpected websocket response id")
+	// A cancelled ctx has no deadline, so the pending send or receive is interrupted by moving the deadline to now.
+	// The watcher is stopped before returning, so it can't interrupt the next call on the connection.
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			ws.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	encodeStart := time.Now()
+	sMsg := WebsocketSend{
+		Id:     id,
+		Method: method,
+		Params: params,
 	}
-	if len(msg.Result) == 0 {
-		return nil, errors.New("empty response")
+	request, err := json.Marshal(sMsg)
+	if err != nil {
+		return nil, opStats{}, err
 	}
-	if msg.Result[0]["status"] != "OK" {
-		return nil, errors.New("status not OK")
+	encodeDuration := time.Since(encodeStart)
+	if err := ws.Send(request); err != nil {
+		return nil, opStats{}, err
 	}
-	return msg.Result, nil
-}
 
-func websocketRead(id string, ws *websocket.Conn, msgId int) (int, error) {
-	query := `SELECT * FROM customer:` + id + `;`
-	resp, err := wsSendMessage(ws, msgId, query)
+	response, err := ws.Receive()
 	if err != nil {
-		return 0, err
+		return nil, opStats{}, err
+	}
+	decodeStart := time.Now()
+	var msg WebsocketReceive
+	if err := json.Unmarshal(response, &msg); err != nil {
+		return nil, opStats{}, err
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
+	if msg.Id != id {
+		return nil, opStats{}, errors.New("unexpected websocket response id")
+	}
+	if msg.Error != nil {
+		return nil, opStats{}, fmt.Errorf("rpc error %d: %s", msg.Error.Code, msg.Error.Message)
+	}
+	result, err := decode(msg.Result)
 	if err != nil {
-		return 0, err
+		return nil, opStats{}, err
 	}
-	return int(internalDur.Microseconds()), nil
+	decodeDuration := time.Since(decodeStart)
+	stats := opStats{
+		InternalDuration: -1,
+		RequestBytes:     len(request),
+		ResponseBytes:    len(response),
+		Records:          len(result),
+		EncodeDuration:   int(encodeDuration.Microseconds()),
+		DecodeDuration:   int(decodeDuration.Microseconds()),
+	}
+	return result, stats, nil
 }
 
-func websocketDelete(id string, ws *websocket.Conn, msgId int) (int, error) {
-	query := `DELETE customer:` + id + `;`
-	resp, err := wsSendMessage(ws, msgId, query)
-	if err != nil {
-		return 0, err
+func websocketRead(ctx context.Context, id string, ws wsConn, msgId int) (opStats, error) {
+	if seededKeys != nil {
+		return websocketSeededRead(ctx, ws, msgId)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, readQuery, recordVars(id))
+	if err == nil && validateResponses {
+		err = validateCustomer(statementResult(resp), "customer:"+id, createdEmail)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func websocketUpdate(id string, ws *websocket.Conn, msgId int) (int, error) {
-	query := `UPDATE customer:` + id + ` SET email = 'test2@test.com';`
-	resp, err := wsSendMessage(ws, msgId, query)
-	if err != nil {
-		return 0, err
+// websocketDelete deletes the customer with message id msgId. In validation mode the customer is read again afterwards
+// with message id msgId+1, to check that it's gone.
+func websocketDelete(ctx context.Context, id string, ws wsConn, msgId int) (opStats, error) {
+	_, stats, err := wsSendMessage(ctx, ws, msgId, deleteQuery, recordVars(id))
+	if err != nil || !validateResponses {
+		return stats, err
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
+	resp, _, err := wsSendMessage(ctx, ws, msgId+1, readQuery, recordVars(id))
 	if err != nil {
-		return 0, err
+		return stats, err
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, validateDeleted(statementResult(resp), id)
 }
 
-func websocketCreate(ws *websocket.Conn, msgId int) (string, int, error) {
-	query := `CREATE customer SET first_name='Test', last_name = 'Tester', email = 'test@test.com', country = 'Germany', last_login = "2024-02-03T21:31:22+0000";`
-	resp, err := wsSendMessage(ws, msgId, query)
-	if err != nil {
-		return "", 0, err
+func websocketUpdate(ctx context.Context, id string, ws wsConn, msgId int) (opStats, error) {
+	if seededKeys != nil {
+		return websocketSeededUpdate(ctx, ws, msgId)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return "", 0, err
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, updateQuery, updateVars(id))
+	if err == nil && validateResponses {
+		err = validateCustomer(statementResult(resp), "customer:"+id, updatedEmail)
 	}
-	fullId := resp[0]["result"].([]interface{})[0].(map[string]interface{})["id"].(string)
-	id := strings.Split(fullId, ":")[1]
-	return id, int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func websocketSelect(ws *websocket.Conn, msgId int) (int, error) {
-	query := `SELECT * FROM order LIMIT 1000`
-	resp, err := wsSendMessage(ws, msgId, query)
+func websocketCreate(ctx context.Context, ws wsConn, msgId int) (string, opStats, error) {
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, createQuery, createVars())
 	if err != nil {
-		return 0, err
+		return "", stats, err
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	if validateResponses {
+		if err := validateCustomer(statementResult(resp), "", createdEmail); err != nil {
+			return "", stats, err
+		}
 	}
-	return int(internalDur.Microseconds()), nil
+	id := strings.Split(createdId(resp), ":")[1]
+	return id, stats, nil
 }
 
-func websocketSimpleQuery(ws *websocket.Conn, msgId int) (int, error) {
-	query := `SELECT * FROM order WHERE processed IS FALSE LIMIT 1000`
-	resp, err := wsSendMessage(ws, msgId, query)
-	if err != nil {
-		return 0, err
+func websocketSelect(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, selectQuery, limitVars())
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), selectCheck)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	return stats, err
+}
+
+func websocketSimpleQuery(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, simpleQuery, processedVars(false))
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), simpleQueryCheck)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func websocketJoinRelation(ws *websocket.Conn, msgId int) (int, error) {
-	query := `SELECT books.title FROM order WHERE processed IS TRUE LIMIT 1000`
-	resp, err := wsSendMessage(ws, msgId, query)
-	if err != nil {
-		return 0, err
+func websocketJoinRelation(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	if seededKeys != nil {
+		return websocketSeededJoinRelation(ctx, ws, msgId)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, joinRelationQuery, processedVars(true))
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), joinRelationCheck)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
 
-func websocketJoinGraph(ws *websocket.Conn, msgId int) (int, error) {
-	query := `SELECT <-ordered<-customer.first_name FROM order WHERE processed IS TRUE LIMIT 1000`
-	resp, err := wsSendMessage(ws, msgId, query)
-	if err != nil {
-		return 0, err
+func websocketJoinGraph(ctx context.Context, ws wsConn, msgId int) (opStats, error) {
+	if seededKeys != nil {
+		return websocketSeededJoinGraph(ctx, ws, msgId)
 	}
-	internalDur, err := time.ParseDuration(resp[0]["time"].(string))
-	if err != nil {
-		return 0, err
+	resp, stats, err := wsSendMessage(ctx, ws, msgId, joinGraphQuery, processedVars(true))
+	if err == nil && validateResponses {
+		err = validateRows(statementResult(resp), joinGraphCheck)
 	}
-	return int(internalDur.Microseconds()), nil
+	return stats, err
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"golang.org/x/net/websocket"
+)
+
+// Websocket libraries of the raw Websocket driver. The SDK always uses gorilla.
+const (
+	wsLibraryXnet    = "xnet"
+	wsLibraryGorilla = "gorilla"
+)
+
+// maxMessageBytes is the maximum size of a received message, large enough for the results of every operation.
+const maxMessageBytes = 1 << 30
+
+// wsOptions configures the connections of the raw Websocket driver.
+type wsOptions struct {
+	Library string
+	// Compression enables permessage-deflate, which is only supported by gorilla
+	Compression      bool
+	CompressionLevel int
+}
+
+func (o wsOptions) validate() error {
+	switch o.Library {
+	case wsLibraryXnet:
+		if o.Compression {
+			return errors.New("permessage-deflate is not supported by " + wsLibraryXnet)
+		}
+	case wsLibraryGorilla:
+		if o.Compression && (o.CompressionLevel < -2 || o.CompressionLevel > 9) {
+			return fmt.Errorf("invalid compression level %d", o.CompressionLevel)
+		}
+	default:
+		return fmt.Errorf("unknown websocket library %q", o.Library)
+	}
+	return nil
+}
+
+// wsConn is a connection of the raw Websocket driver. Every message is sent as a text message.
+type wsConn interface {
+	SetDeadline(t time.Time) error
+	Send(message []byte) error
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// dialWebsocket opens a connection to wsUrl with the library set in websocketOptions.
+func dialWebsocket() (wsConn, error) {
+	if websocketOptions.Library == wsLibraryGorilla {
+		return dialGorilla(websocketOptions)
+	}
+	return dialXnet()
+}
+
+type xnetConn struct {
+	ws *websocket.Conn
+}
+
+func dialXnet() (wsConn, error) {
+	config, err := websocket.NewConfig(wsUrl, url)
+	if err != nil {
+		return nil, err
+	}
+	config.Dialer = &net.Dialer{Timeout: operationTimeout}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	ws.MaxPayloadBytes = maxMessageBytes
+	return &xnetConn{ws: ws}, nil
+}
+
+func (c *xnetConn) SetDeadline(t time.Time) error {
+	return c.ws.SetDeadline(t)
+}
+
+func (c *xnetConn) Send(message []byte) error {
+	return websocket.Message.Send(c.ws, string(message))
+}
+
+func (c *xnetConn) Receive() ([]byte, error) {
+	var message []byte
+	err := websocket.Message.Receive(c.ws, &message)
+	return message, err
+}
+
+func (c *xnetConn) Close() error {
+	return c.ws.Close()
+}
+
+type gorillaConn struct {
+	ws *gorilla.Conn
+}
+
+// compressionWarning makes sure a server that doesn't accept permessage-deflate is only reported once.
+var compressionWarning sync.Once
+
+func dialGorilla(opts wsOptions) (wsConn, error) {
+	dialer := gorilla.Dialer{
+		HandshakeTimeout:  operationTimeout,
+		EnableCompression: opts.Compression,
+	}
+	ws, resp, err := dialer.Dial(wsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(maxMessageBytes)
+	if opts.Compression {
+		ws.EnableWriteCompression(true)
+		if err := ws.SetCompressionLevel(opts.CompressionLevel); err != nil {
+			ws.Close()
+			return nil, err
+		}
+		if !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+			compressionWarning.Do(func() {
+				log.Println("Server didn't accept permessage-deflate, messages are sent uncompressed")
+			})
+		}
+	}
+	return &gorillaConn{ws: ws}, nil
+}
+
+func (c *gorillaConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *gorillaConn) Send(message []byte) error {
+	return c.ws.WriteMessage(gorilla.TextMessage, message)
+}
+
+func (c *gorillaConn) Receive() ([]byte, error) {
+	_, message, err := c.ws.ReadMessage()
+	return message, err
+}
+
+func (c *gorillaConn) Close() error {
+	return c.ws.Close()
+}
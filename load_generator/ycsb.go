@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ycsbTable = "usertable"
+	// ycsbMaxScanLength is the maximum number of records of a scan, the length is uniform between 1 and it
+	ycsbMaxScanLength = 100
+	// ycsbLoadBatch is the number of records inserted per request while loading the table
+	ycsbLoadBatch = 1000
+)
+
+// Query templates of the YCSB operations. The records have the numeric ids 0 to the number of records - 1.
+const (
+	ycsbReadQuery   = `SELECT * FROM type::thing($tb, $id);`
+	ycsbUpdateQuery = `UPDATE type::thing($tb, $id) MERGE $fields;`
+	ycsbInsertQuery = `CREATE type::thing($tb, $id) CONTENT $fields;`
+)
+
+// ycsbScanQuery returns the query of a scan of the records from the key on.
+func ycsbScanQuery(key int64) string {
+	return `SELECT * FROM ` + recordRange(ycsbTable, key) + ` LIMIT $limit;`
+}
+
+// YCSB operations, logged as query type ycsb_<operation>.
+const (
+	ycsbRead            = "read"
+	ycsbUpdate          = "update"
+	ycsbInsert          = "insert"
+	ycsbScan            = "scan"
+	ycsbReadModifyWrite = "read_modify_write"
+)
+
+// ycsbWorkload is the operation mix of a YCSB core workload.
+type ycsbWorkload struct {
+	name         string
+	proportions  map[string]float64
+	distribution string
+}
+
+var ycsbWorkloads = map[string]ycsbWorkload{
+	"a": {"A", map[string]float64{ycsbRead: 0.5, ycsbUpdate: 0.5}, distributionZipfian},
+	"b": {"B", map[string]float64{ycsbRead: 0.95, ycsbUpdate: 0.05}, distributionZipfian},
+	"c": {"C", map[string]float64{ycsbRead: 1}, distributionZipfian},
+	"d": {"D", map[string]float64{ycsbRead: 0.95, ycsbInsert: 0.05}, distributionLatest},
+	"e": {"E", map[string]float64{ycsbScan: 0.95, ycsbInsert: 0.05}, distributionZipfian},
+	"f": {"F", map[string]float64{ycsbRead: 0.5, ycsbReadModifyWrite: 0.5}, distributionZipfian},
+}
+
+// ycsbOperations is the order in which the proportions of a workload are cumulated.
+var ycsbOperations = []string{ycsbRead, ycsbUpdate, ycsbInsert, ycsbScan, ycsbReadModifyWrite}
+
+type ycsbConfig struct {
+	records     int
+	fields      int
+	fieldLength int
+	// distribution overrides the key distribution of the workloads if set
+	distribution string
+	skew         float64
+}
+
+// parseYcsbWorkloads parses a comma separated list of workloads, e.g. "a,b,c".
+func parseYcsbWorkloads(list string) ([]ycsbWorkload, error) {
+	var workloads []ycsbWorkload
+	for _, field := range strings.Split(list, ",") {
+		workload, ok := ycsbWorkloads[strings.ToLower(strings.TrimSpace(field))]
+		if !ok {
+			return nil, fmt.Errorf("unknown YCSB workload %q", field)
+		}
+		workloads = append(workloads, workload)
+	}
+	return workloads, nil
+}
+
+// ycsbState is shared by the workers of all workloads. Inserted records stay in the table until the end of the
+// benchmark, so later workloads read them as well.
+type ycsbState struct {
+	cfg     ycsbConfig
+	inserts *ycsbInserts
+}
+
+// ycsbInserts hands out the keys of the inserts and tracks which of them were acknowledged. Like the acknowledged
+// counter of YCSB, acknowledged only advances over keys that were inserted together with all lower keys, so the key
+// choosers never pick a record whose insert is still in flight. The key of a failed insert is handed out again.
+type ycsbInserts struct {
+	mu           sync.Mutex
+	next         int64
+	failed       []int64
+	inserted     map[int64]bool
+	acknowledged int64
+}
+
+func newYcsbInserts(records int64) *ycsbInserts {
+	return &ycsbInserts{next: records, inserted: make(map[int64]bool), acknowledged: records}
+}
+
+// key returns the key of the next insert.
+func (i *ycsbInserts) key() int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if n := len(i.failed); n > 0 {
+		key := i.failed[n-1]
+		i.failed = i.failed[:n-1]
+		return key
+	}
+	key := i.next
+	i.next++
+	return key
+}
+
+// finish records the end of the insert of key.
+func (i *ycsbInserts) finish(key int64, inserted bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !inserted {
+		i.failed = append(i.failed, key)
+		return
+	}
+	i.inserted[key] = true
+	for i.inserted[i.acknowledged] {
+		delete(i.inserted, i.acknowledged)
+		i.acknowledged++
+	}
+}
+
+// records returns the number of acknowledged records.
+func (i *ycsbInserts) records() int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.acknowledged
+}
+
+// ycsbPhase is a workload running on a connection type.
+type ycsbPhase struct {
+	state    *ycsbState
+	workload ycsbWorkload
+	keys     keyChooser
+}
+
+// runYcsbBenchmark loads the usertable, runs every workload on every connection type and removes the table again.
+func runYcsbBenchmark(duration time.Duration, workers int, workloads []ycsbWorkload, cfg ycsbConfig) error {
+	log.Printf("Starting YCSB benchmark with %d workers for %d minutes per workload and connection type on %d records \n", workers, int(duration.Minutes()), cfg.records)
+
+	state := &ycsbState{cfg: cfg, inserts: newYcsbInserts(int64(cfg.records))}
+	if err := ycsbLoad(cfg); err != nil {
+		ycsbCleanup()
+		return err
+	}
+
+	for _, workload := range workloads {
+		distribution := workload.distribution
+		if cfg.distribution != "" {
+			distribution = cfg.distribution
+		}
+		keys, err := newKeyChooser(distribution, cfg.skew, int64(cfg.records), state.inserts.records)
+		if err != nil {
+			ycsbCleanup()
+			return err
+		}
+		phase := &ycsbPhase{state: state, workload: workload, keys: keys}
+
+		for _, connection := range []string{"REST", "Websocket", "SDK"} {
+			runPhase("YCSB "+workload.name+" "+connection, duration, workers, runQueryWorkers(connection, false, phase.run))
+		}
+	}
+
+	if err := ycsbCleanup(); err != nil {
+		return err
+	}
+
+	log.Println("YCSB benchmark finished")
+	return nil
+}
+
+// ycsbLoad inserts the records 0 to cfg.records - 1 with all fields in batches of ycsbLoadBatch records.
+func ycsbLoad(cfg ycsbConfig) error {
+	log.Printf("Loading %d records into %s \n", cfg.records, ycsbTable)
+	for start := 0; start < cfg.records; start += ycsbLoadBatch {
+		end := start + ycsbLoadBatch
+		if end > cfg.records {
+			end = cfg.records
+		}
+		records := make([]map[string]interface{}, 0, end-start)
+		for key := start; key < end; key++ {
+			record := ycsbFields(cfg, cfg.fields)
+			record["id"] = key
+			records = append(records, record)
+		}
+		if err := insertRecords(ycsbTable, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ycsbCleanup removes the usertable with the loaded and inserted records.
+func ycsbCleanup() error {
+	_, _, err := doQuery(context.Background(), `REMOVE TABLE `+ycsbTable+`;`, nil, new(restTrace))
+	return err
+}
+
+// ycsbFields returns count random fields, field0 to field<count-1> for inserts and a random field for updates.
+func ycsbFields(cfg ycsbConfig, count int) map[string]interface{} {
+	fields := make(map[string]interface{}, count)
+	if count < cfg.fields {
+		fields["field"+strconv.Itoa(rand.Intn(cfg.fields))] = randomString(cfg.fieldLength)
+		return fields
+	}
+	for i := 0; i < count; i++ {
+		fields["field"+strconv.Itoa(i)] = randomString(cfg.fieldLength)
+	}
+	return fields
+}
+
+const randomLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomString(length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = randomLetters[rand.Intn(len(randomLetters))]
+	}
+	return string(b)
+}
+
+// nextOperation picks an operation according to the proportions of the workload.
+func (p *ycsbPhase) nextOperation() string {
+	r := rand.Float64()
+	last := ""
+	for _, operation := range ycsbOperations {
+		proportion, ok := p.workload.proportions[operation]
+		if !ok {
+			continue
+		}
+		last = operation
+		if r < proportion {
+			return operation
+		}
+		r -= proportion
+	}
+	return last
+}
+
+// execute executes operation with exec and returns its stats. A read-modify-write is a read and an update of the
+// same record, its stats are the sum of both.
+func (p *ycsbPhase) execute(operation string, exec queryExecutor) (opStats, error) {
+	cfg := p.state.cfg
+	switch operation {
+	case ycsbRead:
+		_, stats, err := exec(ycsbReadQuery, ycsbVars(p.keys.next()))
+		return stats, err
+	case ycsbUpdate:
+		vars := ycsbVars(p.keys.next())
+		vars["fields"] = ycsbFields(cfg, 1)
+		_, stats, err := exec(ycsbUpdateQuery, vars)
+		return stats, err
+	case ycsbInsert:
+		key := p.state.inserts.key()
+		vars := ycsbVars(key)
+		vars["fields"] = ycsbFields(cfg, cfg.fields)
+		_, stats, err := exec(ycsbInsertQuery, vars)
+		// the insert of a key that failed before may have been applied anyway
+		p.state.inserts.finish(key, err == nil || isAlreadyExists(err))
+		return stats, err
+	case ycsbScan:
+		_, stats, err := exec(ycsbScanQuery(p.keys.next()), map[string]interface{}{"limit": 1 + rand.Intn(ycsbMaxScanLength)})
+		return stats, err
+	default:
+		key := p.keys.next()
+		_, readStats, err := exec(ycsbReadQuery, ycsbVars(key))
+		if err != nil {
+			return readStats, err
+		}
+		vars := ycsbVars(key)
+		vars["fields"] = ycsbFields(cfg, 1)
+		_, updateStats, err := exec(ycsbUpdateQuery, vars)
+		return readStats.add(updateStats), err
+	}
+}
+
+func ycsbVars(key int64) map[string]interface{} {
+	return map[string]interface{}{"tb": ycsbTable, "id": key}
+}
+
+// run executes a random operation of the workload and logs it labelled with the workload.
+func (p *ycsbPhase) run(w queryWorker) (string, time.Time, error) {
+	operation := p.nextOperation()
+	start := time.Now()
+	stats, err := p.execute(operation, w.exec)
+	if err != nil {
+		return "ycsb_" + operation, start, err
+	}
+	res := w.result("ycsb_"+operation, stats, start)
+	res.Workload = p.workload.name
+	saveResult(res)
+	return "", start, nil
+}
@@ -34,11 +34,16 @@ class Book {
     public title: string,
     public description: string,
     public price: number,
-    public isbn: string
+    public isbn: string,
+    public embedding?: number[]
   ) {}
 
   createCommand(): string {
-    return `CREATE book:${this.id} SET title = '${this.title}', description = '${this.description}', price = ${this.price}, isbn = "${this.isbn}" RETURN NONE;\n`;
+    const embedding =
+      this.embedding === undefined
+        ? ""
+        : `, embedding = [${this.embedding.join(",")}]`;
+    return `CREATE book:${this.id} SET title = '${this.title}', description = '${this.description}', price = ${this.price}, isbn = "${this.isbn}"${embedding} RETURN NONE;\n`;
   }
 }
 
@@ -64,17 +69,132 @@ class Order {
   }
 }
 
+class Follows {
+  constructor(public customer_id: number, public followed_id: number) {}
+
+  createCommand(): string {
+    return `RELATE customer:${this.customer_id}->follows->customer:${this.followed_id} RETURN NONE;\n`;
+  }
+}
+
+class Review {
+  constructor(
+    public customer_id: number,
+    public book_id: number,
+    public rating: number,
+    public created_at: Date
+  ) {}
+
+  createCommand(): string {
+    return `RELATE customer:${this.customer_id}->reviewed->book:${
+      this.book_id
+    } SET rating = ${
+      this.rating
+    }, created_at = "${this.created_at.toISOString()}" RETURN NONE;\n`;
+  }
+}
+
+// mulberry32 returns a random generator seeded with seed. The load generator implements the same generator to compute
+// the embeddings of the books, so it must not be changed.
+function mulberry32(seed: number): () => number {
+  return function () {
+    seed |= 0;
+    seed = (seed + 0x6d2b79f5) | 0;
+    let t = Math.imul(seed ^ (seed >>> 15), 1 | seed);
+    t = (t + Math.imul(t ^ (t >>> 7), 61 | t)) ^ t;
+    return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+  };
+}
+
+// bookEmbedding returns the embedding of a book, with components between -1 and 1 rounded to 4 decimals. It only
+// depends on the id of the book and the dimension.
+function bookEmbedding(id: number, dimension: number): number[] {
+  const random = mulberry32(id);
+  const embedding = [];
+  for (let i = 0; i < dimension; i++) {
+    embedding.push(Math.round((random() * 2 - 1) * 10000) / 10000);
+  }
+  return embedding;
+}
+
 function randomInt(min: number, max: number): number {
   return Math.floor(Math.random() * (max - min + 1) + min);
 }
 
-function parseCommandArguments(): string {
+// Power law degrees with exponent 2.5 have the mean 3 * the minimum degree.
+const powerLawExponent = 2.5;
+
+// randomDegree draws an out-degree with the given mean, either uniformly between 0 and 2 * mean or from a power law,
+// where most customers have few edges and some have many. The degree is capped at max.
+function randomDegree(distribution: string, mean: number, max: number): number {
+  if (mean <= 0) {
+    return 0;
+  }
+  let degree: number;
+  if (distribution === "powerlaw") {
+    const minimum = mean / 3;
+    degree = Math.floor(
+      minimum * Math.pow(1 - Math.random(), -1 / (powerLawExponent - 1))
+    );
+  } else {
+    degree = randomInt(0, 2 * mean);
+  }
+  return Math.min(degree, max);
+}
+
+// randomTargets picks count distinct ids between 0 and max, except exclude.
+function randomTargets(count: number, max: number, exclude: number): number[] {
+  const targets = new Set<number>();
+  while (targets.size < count) {
+    const target = randomInt(0, max);
+    if (target !== exclude) {
+      targets.add(target);
+    }
+  }
+  return [...targets];
+}
+
+interface Options {
+  output: string;
+  // mean number of customers every customer follows, 0 to skip the follows edges
+  followsDegree: number;
+  // mean number of books every customer reviews, 0 to skip the reviewed edges
+  reviewsDegree: number;
+  degreeDistribution: string;
+  // dimension of the book embeddings, 0 to skip them
+  embeddingDimension: number;
+}
+
+function parseNumber(value: string | undefined, name: string): number {
+  if (value === undefined) {
+    return 0;
+  }
+  const parsed = parseInt(value, 10);
+  if (isNaN(parsed) || parsed < 0) {
+    throw new Error(`${name} must be a non negative integer`);
+  }
+  return parsed;
+}
+
+function parseCommandArguments(): Options {
   const { values, positionals } = parseArgs({
     args: Bun.argv,
     options: {
       output: {
         type: "string",
       },
+      "follows-degree": {
+        type: "string",
+      },
+      "reviews-degree": {
+        type: "string",
+      },
+      "degree-distribution": {
+        type: "string",
+      },
+      "embedding-dimension": {
+        type: "string",
+      },
     },
     strict: true,
     allowPositionals: true,
@@ -84,18 +204,38 @@ function parseCommandArguments(): string {
     throw new Error("Output path is required");
   }
 
-  return values.output;
+  const degreeDistribution = values["degree-distribution"] ?? "uniform";
+  if (degreeDistribution !== "uniform" && degreeDistribution !== "powerlaw") {
+    throw new Error("Degree distribution must be uniform or powerlaw");
+  }
+
+  return {
+    output: values.output,
+    followsDegree: parseNumber(values["follows-degree"], "Follows degree"),
+    reviewsDegree: parseNumber(values["reviews-degree"], "Reviews degree"),
+    degreeDistribution: degreeDistribution,
+    embeddingDimension: parseNumber(
+      values["embedding-dimension"],
+      "Embedding dimension"
+    ),
+  };
 }
 
 async function main() {
-  var outputPath = parseCommandArguments();
-  const file = Bun.file(outputPath);
+  const options = parseCommandArguments();
+  const file = Bun.file(options.output);
   const writer = file.writer();
 
   writer.write("OPTION IMPORT;\n");
   writer.write(new Table("customer").createCommand());
   writer.write(new Table("book").createCommand());
   writer.write(new Table("order").createCommand());
+  if (options.followsDegree > 0) {
+    writer.write(new Table("follows").createCommand());
+  }
+  if (options.reviewsDegree > 0) {
+    writer.write(new Table("reviewed").createCommand());
+  }
   writer.write("BEGIN TRANSACTION;\n");
 
   const customers_count = 200000;
@@ -130,7 +270,10 @@ async function main() {
             max: 60,
           })
         ),
-        faker.commerce.isbn()
+        faker.commerce.isbn(),
+        options.embeddingDimension > 0
+          ? bookEmbedding(i, options.embeddingDimension)
+          : undefined
       ).createCommand()
     );
   }
@@ -156,6 +299,38 @@ async function main() {
 
   console.log("Orders created");
 
+  if (options.followsDegree > 0) {
+    for (let i = 0; i < customers_count; i++) {
+      const degree = randomDegree(
+        options.degreeDistribution,
+        options.followsDegree,
+        customers_count - 1
+      );
+      for (const followed_id of randomTargets(degree, customers_count - 1, i)) {
+        writer.write(new Follows(i, followed_id).createCommand());
+      }
+    }
+
+    console.log("Follows created");
+  }
+
+  if (options.reviewsDegree > 0) {
+    for (let i = 0; i < customers_count; i++) {
+      const degree = randomDegree(
+        options.degreeDistribution,
+        options.reviewsDegree,
+        books_count
+      );
+      for (const book_id of randomTargets(degree, books_count - 1, -1)) {
+        writer.write(
+          new Review(i, book_id, randomInt(1, 5), faker.date.past()).createCommand()
+        );
+      }
+    }
+
+    console.log("Reviews created");
+  }
+
   writer.write("COMMIT TRANSACTION;\n");
   writer.end();
 }